@@ -3,7 +3,10 @@ module geofile
 go 1.24
 
 require (
+	github.com/maxmind/mmdbwriter v1.0.0
+	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/xtls/xray-core v1.250803.0
+	go4.org/netipx v0.0.0-20231129151722-fdeea329fbba
 	google.golang.org/protobuf v1.36.8
 )
 
@@ -12,7 +15,6 @@ require (
 	github.com/google/btree v1.1.3 // indirect
 	github.com/pires/go-proxyproto v0.8.1 // indirect
 	github.com/sagernet/sing v0.7.5 // indirect
-	go4.org/netipx v0.0.0-20231129151722-fdeea329fbba // indirect
 	golang.org/x/crypto v0.41.0 // indirect
 	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,179 @@
+package tool
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// asnCode 返回单个 ASN 对应的 geoip 代码，例如 AS13335。
+func asnCode(asn uint32) string {
+	return "AS" + strconv.FormatUint(uint64(asn), 10)
+}
+
+// asnTargets 根据 custom.toml 中的 [geoip] 配置，返回 ASN 到目标代码列表的映射。
+// asns 中的每个 ASN 生成 AS<编号> 代码；asngroups 中的每个分组生成以分组名（大写）命名的代码。
+func asnTargets(config *Config) map[uint32][]string {
+	targets := make(map[uint32][]string)
+	for _, asn := range config.GeoIP.ASNs {
+		targets[asn] = append(targets[asn], asnCode(asn))
+	}
+
+	// 按分组名排序，保证输出稳定
+	groups := make([]string, 0, len(config.GeoIP.ASNGroups))
+	for group := range config.GeoIP.ASNGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		code := strings.ToUpper(group)
+		for _, asn := range config.GeoIP.ASNGroups[group] {
+			targets[asn] = append(targets[asn], code)
+		}
+	}
+	return targets
+}
+
+// checkASNCodes 检查 ASN 和 ASN 分组生成的代码是否与 data 目录或其他数据源中的代码重名。
+// 重名时 ASN 的网段会被合并到已有代码中（例如名为 cn 的 ASN 分组会并入 CN 并写入 MMDB），因此返回错误。
+// 需要在读取 ASN 数据源之前调用，此时 dataDirMap 中只有 ASN 以外的代码。
+func checkASNCodes(config *Config, dataDirMap map[string][]*router.CIDR) error {
+	asnCodes := make(map[string]bool, len(config.GeoIP.ASNs))
+	for _, asn := range config.GeoIP.ASNs {
+		code := asnCode(asn)
+		if _, found := dataDirMap[code]; found {
+			return fmt.Errorf("%s [geoip] asns: %s conflicts with an existing code", *customPath, code)
+		}
+		asnCodes[code] = true
+	}
+
+	groups := make([]string, 0, len(config.GeoIP.ASNGroups))
+	for group := range config.GeoIP.ASNGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	names := make(map[string]string, len(groups)) // 代码 -> 配置中的原始分组名
+	for _, group := range groups {
+		code := strings.ToUpper(group)
+		if _, found := dataDirMap[code]; found {
+			return fmt.Errorf("%s [geoip.asngroups.%s]: conflicts with an existing code", *customPath, group)
+		}
+		if asnCodes[code] {
+			return fmt.Errorf("%s [geoip.asngroups.%s]: conflicts with %s in asns", *customPath, group, code)
+		}
+		if other, found := names[code]; found {
+			return fmt.Errorf("%s [geoip.asngroups.%s]: conflicts with [geoip.asngroups.%s]", *customPath, group, other)
+		}
+		names[code] = group
+	}
+	return nil
+}
+
+// parseASNList 解析 ASN 字段，支持 CAIDA pfx2as 的多源（"a_b"）和 AS_SET（"a,b"）写法。
+func parseASNList(s string) ([]uint32, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == ','
+	})
+	asns := make([]uint32, 0, len(fields))
+	for _, field := range fields {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(field), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASN: %s", field)
+		}
+		asns = append(asns, uint32(asn))
+	}
+	return asns, nil
+}
+
+// addASNCIDRs 将 CIDR 追加到 asns 中每个 ASN 的所有目标代码下。
+func addASNCIDRs(asns []uint32, cidrs []*router.CIDR, targets map[uint32][]string, dataDirMap map[string][]*router.CIDR) {
+	for _, asn := range asns {
+		for _, code := range targets[asn] {
+			dataDirMap[code] = append(dataDirMap[code], cidrs...)
+		}
+	}
+}
+
+// getCidrFromASNTable 读取 IPtoASN 或 CAIDA pfx2as 表（paths 为逗号分隔的文件列表），
+// 为配置中的 ASN 和 ASN 分组生成 geoip 代码。
+//   - IPtoASN（ip2asn-v4.tsv 等）：range_start<TAB>range_end<TAB>AS_number<TAB>country_code<TAB>AS_description
+//   - CAIDA pfx2as：prefix<TAB>length<TAB>ASN
+func getCidrFromASNTable(paths string, isPfx2as bool, config *Config, dataDirMap map[string][]*router.CIDR) error {
+	targets := asnTargets(config)
+	if len(targets) == 0 {
+		fmt.Println("No ASN configured in [geoip] section, skipped ASN table.")
+		return nil
+	}
+
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := readASNTable(path, isPfx2as, targets, dataDirMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readASNTable 解析单个 ASN 表文件。
+func readASNTable(path string, isPfx2as bool, targets map[uint32][]string, dataDirMap map[string][]*router.CIDR) error {
+	file, err := openCompressed(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if isEmpty(line) || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return fmt.Errorf("%s:%d: expected at least 3 columns, got %d", path, lineNum, len(fields))
+		}
+		asns, err := parseASNList(fields[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+
+		// 先检查是否有需要的 ASN，避免解析无关的网段
+		wanted := false
+		for _, asn := range asns {
+			if len(targets[asn]) > 0 {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			continue
+		}
+
+		var cidrs []*router.CIDR
+		if isPfx2as {
+			var cidr *router.CIDR
+			cidr, err = ParseIP(fields[0] + "/" + fields[1])
+			cidrs = []*router.CIDR{cidr}
+		} else {
+			from, to, rangeErr := parseRangeBounds(fields[0], fields[1])
+			if rangeErr != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNum, rangeErr)
+			}
+			cidrs, err = rangeToCIDRs(from, to)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		addASNCIDRs(asns, cidrs, targets, dataDirMap)
+	}
+	return scanner.Err()
+}
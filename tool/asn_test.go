@@ -0,0 +1,34 @@
+package tool
+
+import (
+	"testing"
+)
+
+func TestCheckASNCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		asns      []uint32
+		asnGroups map[string][]uint32
+		wantErr   bool
+	}{
+		{name: "no conflict", asns: []uint32{4134, 13335}, asnGroups: map[string][]uint32{"cloudflare": {13335, 209242}}},
+		{name: "asn conflicts with a data file", asns: []uint32{4134, 9808}, wantErr: true},
+		{name: "group conflicts with a country code", asnGroups: map[string][]uint32{"cn": {4134}}, wantErr: true},
+		{name: "group conflicts with a data file", asnGroups: map[string][]uint32{"As9808": {9808}}, wantErr: true},
+		{name: "group conflicts with an asn", asns: []uint32{13335}, asnGroups: map[string][]uint32{"as13335": {13335}}, wantErr: true},
+		{name: "groups differ only in case", asnGroups: map[string][]uint32{"cloudflare": {13335}, "Cloudflare": {209242}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cidrList := testCIDRList(t, map[string][]string{"AS9808": {"120.196.0.0/14"}})
+			config := &Config{}
+			config.GeoIP.ASNs = test.asns
+			config.GeoIP.ASNGroups = test.asnGroups
+
+			if err := checkASNCodes(config, cidrList); (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package tool
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+	"go4.org/netipx"
+)
+
+// cidrToPrefix 将 router.CIDR 转换为 netip.Prefix。
+func cidrToPrefix(cidr *router.CIDR) (netip.Prefix, error) {
+	addr, ok := netip.AddrFromSlice(cidr.GetIp())
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("invalid ip length: %d", len(cidr.GetIp()))
+	}
+	prefix, err := addr.Prefix(int(cidr.GetPrefix()))
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix, nil
+}
+
+// prefixToCIDR 将 netip.Prefix 转换为 router.CIDR。
+func prefixToCIDR(prefix netip.Prefix) *router.CIDR {
+	return &router.CIDR{
+		Ip:     prefix.Addr().AsSlice(),
+		Prefix: uint32(prefix.Bits()),
+	}
+}
+
+// formatCIDRs 将 router.CIDR 列表格式化为以空格分隔的 CIDR 字符串，用于日志输出。
+func formatCIDRs(cidrs []*router.CIDR) string {
+	parts := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if prefix, err := cidrToPrefix(cidr); err == nil {
+			parts = append(parts, prefix.String())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitCIDRsByFamily 将 CIDR 列表按地址族拆分为 IPv4 和 IPv6 两个列表。
+func splitCIDRsByFamily(cidrs []*router.CIDR) (ipv4, ipv6 []*router.CIDR) {
+	ipv4 = make([]*router.CIDR, 0, len(cidrs))
+	ipv6 = make([]*router.CIDR, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if len(cidr.GetIp()) == 4 {
+			ipv4 = append(ipv4, cidr)
+		} else {
+			ipv6 = append(ipv6, cidr)
+		}
+	}
+	return ipv4, ipv6
+}
+
+// rangeToCIDRs 将起止地址（包含两端）表示的范围转换为覆盖该范围的最小 router.CIDR 列表。
+func rangeToCIDRs(from, to netip.Addr) ([]*router.CIDR, error) {
+	ipRange := netipx.IPRangeFrom(from, to)
+	if !ipRange.IsValid() {
+		return nil, fmt.Errorf("invalid ip range: %s-%s", from, to)
+	}
+
+	prefixes := ipRange.Prefixes()
+	result := make([]*router.CIDR, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result = append(result, prefixToCIDR(prefix))
+	}
+	return result, nil
+}
+
+// cidrsToIPSetBuilder 将 CIDR 列表添加到一个新的 netipx.IPSetBuilder 中。
+func cidrsToIPSetBuilder(cidrs []*router.CIDR) (*netipx.IPSetBuilder, error) {
+	builder := new(netipx.IPSetBuilder)
+	for _, cidr := range cidrs {
+		prefix, err := cidrToPrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		builder.AddPrefix(prefix)
+	}
+	return builder, nil
+}
+
+// ipSetToCIDRs 将 netipx.IPSet 转换为最小的 router.CIDR 列表（IPv4 在前，IPv6 在后）。
+func ipSetToCIDRs(set *netipx.IPSet) []*router.CIDR {
+	prefixes := set.Prefixes()
+	result := make([]*router.CIDR, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result = append(result, prefixToCIDR(prefix))
+	}
+	return result
+}
+
+// aggregateCIDRs 对 CIDR 列表进行聚合：
+// 移除重复和被覆盖的前缀，并将相邻的兄弟前缀合并为更短的前缀，得到最小的前缀集合。
+// IPv4 和 IPv6 分别处理，结果中 IPv4 在前，IPv6 在后。
+func aggregateCIDRs(cidrs []*router.CIDR) ([]*router.CIDR, error) {
+	builder, err := cidrsToIPSetBuilder(cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := builder.IPSet()
+	if err != nil {
+		return nil, err
+	}
+	return ipSetToCIDRs(set), nil
+}
@@ -0,0 +1,256 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// CloudSource 描述一个云服务商发布的 IP 范围 JSON 文件（custom.toml 中的 [[geoip.cloud]]）。
+type CloudSource struct {
+	Provider string   // aws、gcp、azure 或 oracle
+	Path     string   // 本地 JSON 文件路径
+	Regions  []string // 仅保留这些区域（为空表示全部）
+	Services []string // 仅保留这些服务（为空表示全部）
+}
+
+// awsIPRanges 是 AWS ip-ranges.json 的结构。
+type awsIPRanges struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+// gcpCloudRanges 是 GCP cloud.json 的结构，scope 即区域。
+type gcpCloudRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+// azureServiceTags 是 Azure Service Tags JSON 的结构。
+type azureServiceTags struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// oracleIPRanges 是 Oracle public_ip_ranges.json 的结构。
+type oracleIPRanges struct {
+	Regions []struct {
+		Region string `json:"region"`
+		CIDRs  []struct {
+			CIDR string   `json:"cidr"`
+			Tags []string `json:"tags"`
+		} `json:"cidrs"`
+	} `json:"regions"`
+}
+
+// cloudCode 将名称各部分转换为 geoip 代码，例如 ("aws", "cn-north-1") -> AWS-CN-NORTH-1。
+func cloudCode(parts ...string) string {
+	code := strings.ToUpper(strings.Join(parts, "-"))
+	return strings.NewReplacer(".", "-", "_", "-", " ", "-").Replace(code)
+}
+
+// cloudCodes 返回一条记录的目标代码：服务商代码，以及（sub 非空时）服务商-区域/服务标签代码。
+func cloudCodes(provider, sub string) []string {
+	if strings.TrimSpace(sub) == "" {
+		return []string{cloudCode(provider)}
+	}
+	return []string{cloudCode(provider), cloudCode(provider, sub)}
+}
+
+// cloudFilter 根据区域和服务过滤条件检查一条记录是否需要保留（不区分大小写）。
+type cloudFilter struct {
+	regions  map[string]bool
+	services map[string]bool
+}
+
+func newCloudFilter(source *CloudSource) *cloudFilter {
+	toSet := func(values []string) map[string]bool {
+		if len(values) == 0 {
+			return nil
+		}
+		set := make(map[string]bool, len(values))
+		for _, value := range values {
+			set[strings.ToLower(value)] = true
+		}
+		return set
+	}
+	return &cloudFilter{regions: toSet(source.Regions), services: toSet(source.Services)}
+}
+
+// keep 检查区域和服务是否满足过滤条件；services 中任意一个匹配即视为满足服务条件。
+func (f *cloudFilter) keep(region string, services ...string) bool {
+	if f.regions != nil && !f.regions[strings.ToLower(region)] {
+		return false
+	}
+	if f.services == nil {
+		return true
+	}
+	for _, service := range services {
+		if f.services[strings.ToLower(service)] {
+			return true
+		}
+	}
+	return false
+}
+
+// addCloudPrefix 解析网段并将其追加到所有目标代码下。
+func addCloudPrefix(prefix string, codes []string, dataDirMap map[string][]*router.CIDR) error {
+	cidr, err := ParseIP(strings.TrimSpace(prefix))
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		dataDirMap[code] = append(dataDirMap[code], cidr)
+	}
+	return nil
+}
+
+// getCidrFromCloud 读取 custom.toml 中配置的所有云服务商 IP 范围文件。
+// 每个服务商生成一个包含全部保留网段的代码（如 AWS），以及按区域或服务标签划分的代码：
+//   - aws：AWS、AWS-<region>
+//   - gcp：GCP、GCP-<scope>
+//   - azure：AZURE、AZURE-<service tag>（如 AZURE-AZURECLOUD、AZURE-AZURECLOUD-EASTUS）
+//   - oracle：ORACLE、ORACLE-<region>
+//
+// 服务过滤条件分别匹配 AWS 的 service、GCP 的 service、Azure 的 systemService 或服务标签名（不含区域后缀）、
+// Oracle 的 tags。
+func getCidrFromCloud(sources []CloudSource, dataDirMap map[string][]*router.CIDR) error {
+	for i := range sources {
+		source := &sources[i]
+		data, err := os.ReadFile(source.Path)
+		if err != nil {
+			return err
+		}
+
+		filter := newCloudFilter(source)
+		switch strings.ToLower(source.Provider) {
+		case "aws":
+			err = readAWSRanges(data, filter, dataDirMap)
+		case "gcp":
+			err = readGCPRanges(data, filter, dataDirMap)
+		case "azure":
+			err = readAzureServiceTags(data, filter, dataDirMap)
+		case "oracle":
+			err = readOracleRanges(data, filter, dataDirMap)
+		default:
+			err = fmt.Errorf("unknown cloud provider: %s", source.Provider)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source.Path, err)
+		}
+	}
+	return nil
+}
+
+// readAWSRanges 解析 AWS ip-ranges.json。
+func readAWSRanges(data []byte, filter *cloudFilter, dataDirMap map[string][]*router.CIDR) error {
+	var ranges awsIPRanges
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return err
+	}
+
+	for _, prefix := range ranges.Prefixes {
+		if !filter.keep(prefix.Region, prefix.Service) {
+			continue
+		}
+		if err := addCloudPrefix(prefix.IPPrefix, cloudCodes("aws", prefix.Region), dataDirMap); err != nil {
+			return err
+		}
+	}
+	for _, prefix := range ranges.IPv6Prefixes {
+		if !filter.keep(prefix.Region, prefix.Service) {
+			continue
+		}
+		if err := addCloudPrefix(prefix.IPv6Prefix, cloudCodes("aws", prefix.Region), dataDirMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readGCPRanges 解析 GCP cloud.json。
+func readGCPRanges(data []byte, filter *cloudFilter, dataDirMap map[string][]*router.CIDR) error {
+	var ranges gcpCloudRanges
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return err
+	}
+
+	for _, prefix := range ranges.Prefixes {
+		if !filter.keep(prefix.Scope, prefix.Service) {
+			continue
+		}
+		for _, cidr := range []string{prefix.IPv4Prefix, prefix.IPv6Prefix} {
+			if cidr == "" {
+				continue
+			}
+			if err := addCloudPrefix(cidr, cloudCodes("gcp", prefix.Scope), dataDirMap); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readAzureServiceTags 解析 Azure Service Tags JSON，每个服务标签生成一个代码。
+func readAzureServiceTags(data []byte, filter *cloudFilter, dataDirMap map[string][]*router.CIDR) error {
+	var tags azureServiceTags
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return err
+	}
+
+	for _, tag := range tags.Values {
+		baseName, _, _ := strings.Cut(tag.Name, ".")
+		if !filter.keep(tag.Properties.Region, tag.Properties.SystemService, baseName) {
+			continue
+		}
+		codes := cloudCodes("azure", tag.Name)
+		for _, prefix := range tag.Properties.AddressPrefixes {
+			if err := addCloudPrefix(prefix, codes, dataDirMap); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readOracleRanges 解析 Oracle public_ip_ranges.json。
+func readOracleRanges(data []byte, filter *cloudFilter, dataDirMap map[string][]*router.CIDR) error {
+	var ranges oracleIPRanges
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return err
+	}
+
+	for _, region := range ranges.Regions {
+		codes := cloudCodes("oracle", region.Region)
+		for _, cidr := range region.CIDRs {
+			if !filter.keep(region.Region, cidr.Tags...) {
+				continue
+			}
+			if err := addCloudPrefix(cidr.CIDR, codes, dataDirMap); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,16 +1,18 @@
 package tool
 
 import (
+	"compress/bzip2"
+	"compress/gzip"
 	"fmt"
 	"go/build"
+	"io"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	router "github.com/xtls/xray-core/app/router"
-	"github.com/xtls/xray-core/common/errors"
-	"github.com/xtls/xray-core/common/net"
 )
 
 // fileName 用于表示数据文件（如 CN, GFW 等）的名称类型
@@ -105,6 +107,76 @@ func GetGOPATH() string {
 	return GOPATH
 }
 
+// compressedFile 是解压后的文件内容，关闭时同时关闭底层文件。
+type compressedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *compressedFile) Close() error {
+	return f.file.Close()
+}
+
+// openCompressed 打开指定路径的文件，并根据扩展名自动解压（支持 .gz 和 .bz2）。
+func openCompressed(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &compressedFile{Reader: gz, file: file}, nil
+	case ".bz2":
+		return &compressedFile{Reader: bzip2.NewReader(file), file: file}, nil
+	}
+	return file, nil
+}
+
+// dependencyWalker 记录按依赖关系深度优先处理时各名称的访问状态，用于检测循环依赖。
+// 用于 geosite 列表和 geoip 代码的 include 指令，以及 [geoip.sets] 中集合之间的引用。
+type dependencyWalker[T ~string] struct {
+	kind     string     // 依赖的种类，用于错误信息（如 "include"、"set"）
+	resolved map[T]bool // 已处理完成的名称
+	stack    []T        // 当前正在访问的名称路径，用于输出循环
+}
+
+func newDependencyWalker[T ~string](kind string) *dependencyWalker[T] {
+	return &dependencyWalker[T]{kind: kind, resolved: make(map[T]bool)}
+}
+
+// enter 开始访问 name。name 已处理完成时 done 为 true，调用方无需再处理；
+// name 已在当前访问路径上时返回包含完整循环路径的错误，例如 "include cycle detected: A -> B -> A"。
+func (w *dependencyWalker[T]) enter(name T) (done bool, err error) {
+	if w.resolved[name] {
+		return true, nil
+	}
+	for i, visiting := range w.stack {
+		if visiting == name {
+			cycle := make([]string, 0, len(w.stack)-i+1)
+			for _, n := range w.stack[i:] {
+				cycle = append(cycle, string(n))
+			}
+			cycle = append(cycle, string(name))
+			return false, fmt.Errorf("%s cycle detected: %s", w.kind, strings.Join(cycle, " -> "))
+		}
+	}
+	w.stack = append(w.stack, name)
+	return false, nil
+}
+
+// leave 结束访问当前路径上的最后一个名称，并将其标记为已处理完成。
+func (w *dependencyWalker[T]) leave() {
+	name := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	w.resolved[name] = true
+}
+
 // isEmpty 检查一个已经去除空格的规则行是否为空
 func isEmpty(s string) bool {
 	return len(strings.TrimSpace(s)) == 0
@@ -119,52 +191,87 @@ func removeComment(line string) string {
 	return strings.TrimSpace(line[:idx])
 }
 
+// parseAddr 解析单个 IP 地址，拒绝带有区域标识（如 "fe80::1%eth0"）的地址。
+// 返回的 mapped 表示地址是否为 IPv4 映射的 IPv6 地址（如 "::ffff:1.2.3.4"）。
+func parseAddr(s string) (addr netip.Addr, mapped bool, err error) {
+	addr, err = netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false, fmt.Errorf("unsupported address for router: %s: %w", s, err)
+	}
+	if addr.Zone() != "" {
+		return netip.Addr{}, false, fmt.Errorf("zone-scoped address is not allowed: %s", s)
+	}
+	return addr, addr.Is4In6(), nil
+}
+
 // ParseIP 解析一个 CIDR 字符串（如 "192.168.1.1/24" 或 "::1/128"）
-// 并将其转换为 router.CIDR 结构体
+// 并将其转换为规范化后的 router.CIDR 结构体，规范化规则见 parseCanonicalIP。
 func ParseIP(s string) (*router.CIDR, error) {
-	var addr, mask string
-	i := strings.Index(s, "/")
-	if i < 0 { // 如果没有斜杠，则表示没有掩码，默认使用完整的掩码（IPv4: /32, IPv6: /128）
-		addr = s
-	} else {
-		addr = s[:i]
-		mask = s[i+1:]
-	}
-	ip := net.ParseAddress(addr)
-	switch ip.Family() {
-	case net.AddressFamilyIPv4:
-		bits := uint32(32) // IPv4 默认掩码是 /32
-		if len(mask) > 0 {
-			bits64, err := strconv.ParseUint(mask, 10, 32)
-			if err != nil {
-				return nil, errors.New("invalid network mask for router: ", mask).Base(err)
-			}
-			bits = uint32(bits64)
-		}
-		if bits > 32 {
-			return nil, errors.New("invalid network mask for router: ", bits)
+	cidr, _, err := parseCanonicalIP(s)
+	return cidr, err
+}
+
+// parseCanonicalIP 解析一个 CIDR 字符串并进行规范化：
+//   - 清零主机位，例如 "1.2.3.4/24" 规范化为 "1.2.3.0/24"
+//   - IPv4 映射的 IPv6 前缀转换为 IPv4 前缀，例如 "::ffff:1.2.3.0/120" 规范化为 "1.2.3.0/24"
+//   - 拒绝带有区域标识的地址
+//
+// 返回的 changed 表示规范化是否修改了输入的地址或前缀。
+func parseCanonicalIP(s string) (cidr *router.CIDR, changed bool, err error) {
+	addrStr, mask, hasMask := strings.Cut(s, "/")
+	addr, mapped, err := parseAddr(addrStr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bits := addr.BitLen() // 没有掩码时默认使用完整的掩码（IPv4: /32, IPv6: /128）
+	if hasMask {
+		bits64, err := strconv.ParseUint(strings.TrimSpace(mask), 10, 32)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid network mask for router: %s: %w", mask, err)
 		}
-		return &router.CIDR{
-			Ip:     []byte(ip.IP()),
-			Prefix: bits,
-		}, nil
-	case net.AddressFamilyIPv6:
-		bits := uint32(128) // IPv6 默认掩码是 /128
-		if len(mask) > 0 {
-			bits64, err := strconv.ParseUint(mask, 10, 32)
-			if err != nil {
-				return nil, errors.New("invalid network mask for router: ", mask).Base(err)
-			}
-			bits = uint32(bits64)
+		if int(bits64) > addr.BitLen() {
+			return nil, false, fmt.Errorf("invalid network mask for router: %d", bits64)
 		}
-		if bits > 128 {
-			return nil, errors.New("invalid network mask for router: ", bits)
+		bits = int(bits64)
+	}
+
+	if mapped {
+		// 前缀短于 /96 时包含非映射地址，无法转换为 IPv4 前缀
+		if bits < 96 {
+			return nil, false, fmt.Errorf("IPv4-mapped prefix shorter than /96: %s", s)
 		}
-		return &router.CIDR{
-			Ip:     []byte(ip.IP()),
-			Prefix: bits,
-		}, nil
-	default:
-		return nil, errors.New("unsupported address for router: ", s)
+		addr = addr.Unmap()
+		bits -= 96
+	}
+
+	prefix := netip.PrefixFrom(addr, bits)
+	masked := prefix.Masked()
+	return prefixToCIDR(masked), mapped || masked != prefix, nil
+}
+
+// ParseIPRange 解析一个 IP 范围字符串（如 "1.0.1.0-1.0.3.255" 或 "2001:db8::-2001:db8::ffff"），
+// 并将其展开为覆盖该范围的最小 router.CIDR 列表。起止地址必须属于同一地址族。
+func ParseIPRange(s string) ([]*router.CIDR, error) {
+	cidrs, _, err := parseCanonicalIPRange(s)
+	return cidrs, err
+}
+
+// parseCanonicalIPRange 与 ParseIPRange 相同，但会将 IPv4 映射的 IPv6 起止地址转换为 IPv4 地址，
+// 返回的 changed 表示是否发生了这种转换。
+func parseCanonicalIPRange(s string) (cidrs []*router.CIDR, changed bool, err error) {
+	fromStr, toStr, found := strings.Cut(s, "-")
+	if !found {
+		return nil, false, fmt.Errorf("invalid ip range: %s", s)
+	}
+	from, fromMapped, err := parseAddr(fromStr)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid ip range: %s: %w", s, err)
+	}
+	to, toMapped, err := parseAddr(toStr)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid ip range: %s: %w", s, err)
 	}
+	cidrs, err = rangeToCIDRs(from.Unmap(), to.Unmap())
+	return cidrs, fromMapped || toMapped, err
 }
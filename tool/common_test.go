@@ -0,0 +1,88 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIPRange(t *testing.T) {
+	tests := []struct {
+		in          string
+		want        []string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{in: "1.0.1.0-1.0.3.255", want: []string{"1.0.1.0/24", "1.0.2.0/23"}},
+		{in: "1.0.1.5-1.0.1.5", want: []string{"1.0.1.5/32"}},
+		{in: " 1.0.1.0 - 1.0.1.127 ", want: []string{"1.0.1.0/25"}},
+		{in: "2001:db8::-2001:db8::ffff", want: []string{"2001:db8::/112"}},
+		{in: "2001:db8::1-2001:db8::2", want: []string{"2001:db8::1/128", "2001:db8::2/128"}},
+		{in: "::ffff:1.0.4.0-::ffff:1.0.4.255", want: []string{"1.0.4.0/24"}, wantChanged: true},
+		{in: "1.0.4.0-::ffff:1.0.4.255", want: []string{"1.0.4.0/24"}, wantChanged: true},
+		{in: "1.0.0.0-2001:db8::1", wantErr: true},
+		{in: "1.0.3.255-1.0.1.0", wantErr: true},
+		{in: "2001:db8::ffff-2001:db8::", wantErr: true},
+		{in: "fe80::1%eth0-fe80::2", wantErr: true},
+		{in: "1.0.1.0-", wantErr: true},
+		{in: "1.0.1.0", wantErr: true},
+	}
+
+	for _, test := range tests {
+		cidrs, changed, err := parseCanonicalIPRange(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error %v", test.in, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := prefixStrings(t, cidrs); !reflect.DeepEqual(got, test.want) || changed != test.wantChanged {
+			t.Errorf("%q: got %v (changed %v), want %v (changed %v)", test.in, got, changed, test.want, test.wantChanged)
+		}
+		plain, err := ParseIPRange(test.in)
+		if err != nil || !reflect.DeepEqual(prefixStrings(t, plain), test.want) {
+			t.Errorf("ParseIPRange(%q): got %v, %v", test.in, prefixStrings(t, plain), err)
+		}
+	}
+}
+
+func TestParseCanonicalIP(t *testing.T) {
+	tests := []struct {
+		in          string
+		want        string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{in: "1.2.3.0/24", want: "1.2.3.0/24"},
+		{in: "1.2.3.4", want: "1.2.3.4/32"},
+		{in: "1.2.3.4/24", want: "1.2.3.0/24", wantChanged: true},
+		{in: "2001:db8::1/32", want: "2001:db8::/32", wantChanged: true},
+		{in: "::1", want: "::1/128"},
+		{in: "::ffff:1.2.3.0/120", want: "1.2.3.0/24", wantChanged: true},
+		{in: "::ffff:1.2.3.4", want: "1.2.3.4/32", wantChanged: true},
+		{in: "::ffff:0.0.0.0/95", wantErr: true},
+		{in: "fe80::1%eth0", wantErr: true},
+		{in: "1.2.3.0/33", wantErr: true},
+		{in: "1.2.3.0/x", wantErr: true},
+		{in: "example.com", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		cidr, changed, err := parseCanonicalIP(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error %v", test.in, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		prefix, err := cidrToPrefix(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefix.String() != test.want || changed != test.wantChanged {
+			t.Errorf("%q: got %s (changed %v), want %s (changed %v)", test.in, prefix, changed, test.want, test.wantChanged)
+		}
+	}
+}
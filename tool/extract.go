@@ -0,0 +1,233 @@
+package tool
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// ExtractSource 描述一个通用的 JSON/CSV 数据源（custom.toml 中的 [[geoip.extract]]），
+// 从中提取所有 CIDR 写入指定的代码。多个数据源可以使用同一个代码，结果会合并。
+type ExtractSource struct {
+	Code     string            // 目标代码
+	Path     string            // 本地文件路径
+	Format   string            // json 或 csv，为空时根据扩展名判断
+	JSONPath string            // JSON 路径，例如 "result.ipv4_cidrs"、"prefixes.*.ip_prefix"
+	Column   string            // CSV 中 CIDR 所在的列（列名或从 0 开始的序号）
+	Header   bool              // CSV 第一行是否为表头
+	Filter   map[string]string // CSV 行过滤条件：列（列名或序号）= 值，全部满足才保留
+}
+
+// getCidrFromExtract 读取 custom.toml 中配置的所有通用 JSON/CSV 数据源。
+func getCidrFromExtract(sources []ExtractSource, dataDirMap map[string][]*router.CIDR) error {
+	for i := range sources {
+		source := &sources[i]
+		code := strings.ToUpper(strings.TrimSpace(source.Code))
+		if code == "" {
+			return fmt.Errorf("%s: missing code", source.Path)
+		}
+
+		format := strings.ToLower(source.Format)
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(source.Path)), ".")
+		}
+
+		var values []string
+		var err error
+		switch format {
+		case "json":
+			values, err = extractFromJSON(source)
+		case "csv":
+			values, err = extractFromCSV(source)
+		default:
+			err = fmt.Errorf("unknown format %q, expected json or csv", format)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source.Path, err)
+		}
+
+		for _, value := range values {
+			cidrs, _, err := parseCIDRLine(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source.Path, err)
+			}
+			dataDirMap[code] = append(dataDirMap[code], cidrs...)
+		}
+	}
+	return nil
+}
+
+// extractFromJSON 按 JSON 路径提取字符串值。
+//
+// 路径由 "." 分隔，"*" 表示遍历数组的所有元素或对象的所有值，数字表示数组下标；
+// 在数组上使用普通的键名时，会对数组的每个元素应用该键名。路径指向的值可以是字符串，
+// 也可以是（嵌套的）字符串数组。路径为空时，提取文档中所有能解析为 IP、CIDR 或 IP 范围的字符串。
+func extractFromJSON(source *ExtractSource) ([]string, error) {
+	data, err := os.ReadFile(source.Path)
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	var values []string
+	if strings.TrimSpace(source.JSONPath) == "" {
+		collectJSONStrings(document, &values)
+		// 未指定路径时只保留可以解析的字符串
+		parsable := values[:0]
+		for _, value := range values {
+			if _, _, err := parseCIDRLine(value); err == nil {
+				parsable = append(parsable, value)
+			}
+		}
+		return parsable, nil
+	}
+
+	walkJSONPath(document, strings.Split(source.JSONPath, "."), &values)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("json path %q matched nothing", source.JSONPath)
+	}
+	return values, nil
+}
+
+// walkJSONPath 沿路径遍历 JSON 节点，将路径末端的所有字符串追加到 values 中。
+func walkJSONPath(node any, segments []string, values *[]string) {
+	if len(segments) == 0 {
+		collectJSONStrings(node, values)
+		return
+	}
+
+	segment := strings.TrimSpace(segments[0])
+	switch v := node.(type) {
+	case map[string]any:
+		if segment == "*" {
+			for _, key := range sortedKeys(v) {
+				walkJSONPath(v[key], segments[1:], values)
+			}
+		} else if child, found := v[segment]; found {
+			walkJSONPath(child, segments[1:], values)
+		}
+	case []any:
+		if segment == "*" {
+			for _, elem := range v {
+				walkJSONPath(elem, segments[1:], values)
+			}
+		} else if idx, err := strconv.Atoi(segment); err == nil {
+			if idx >= 0 && idx < len(v) {
+				walkJSONPath(v[idx], segments[1:], values)
+			}
+		} else {
+			// 对数组的每个元素应用同一个键名
+			for _, elem := range v {
+				walkJSONPath(elem, segments, values)
+			}
+		}
+	}
+}
+
+// collectJSONStrings 递归收集 JSON 节点中的所有字符串。
+func collectJSONStrings(node any, values *[]string) {
+	switch v := node.(type) {
+	case string:
+		*values = append(*values, strings.TrimSpace(v))
+	case []any:
+		for _, elem := range v {
+			collectJSONStrings(elem, values)
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			collectJSONStrings(v[key], values)
+		}
+	}
+}
+
+// sortedKeys 返回对象的所有键（排序后），保证遍历顺序稳定。
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// extractFromCSV 从 CSV 的指定列中提取值，并按 Filter 过滤行。
+func extractFromCSV(source *ExtractSource) ([]string, error) {
+	file, err := os.Open(source.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var header []string
+	if source.Header {
+		if header, err = reader.Read(); err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+	}
+
+	column, err := csvColumnIndex(source.Column, header)
+	if err != nil {
+		return nil, err
+	}
+	filters := make(map[int]string, len(source.Filter))
+	for name, value := range source.Filter {
+		idx, err := csvColumnIndex(name, header)
+		if err != nil {
+			return nil, err
+		}
+		filters[idx] = value
+	}
+
+	var values []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keep := column < len(record)
+		for idx, value := range filters {
+			if idx >= len(record) || strings.TrimSpace(record[idx]) != value {
+				keep = false
+				break
+			}
+		}
+		if keep && strings.TrimSpace(record[column]) != "" {
+			values = append(values, strings.TrimSpace(record[column]))
+		}
+	}
+	return values, nil
+}
+
+// csvColumnIndex 将列名或从 0 开始的序号转换为列序号。
+func csvColumnIndex(column string, header []string) (int, error) {
+	column = strings.TrimSpace(column)
+	for idx, name := range header {
+		if strings.TrimSpace(name) == column {
+			return idx, nil
+		}
+	}
+	idx, err := strconv.Atoi(column)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("unknown csv column %q", column)
+	}
+	return idx, nil
+}
@@ -2,8 +2,8 @@ package tool
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"io"
 
 	"os"
 	"path/filepath"
@@ -13,34 +13,41 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// privateCIDRs 是一个包含所有私有、保留或特殊用途 IP CIDR 范围的列表。
-var privateCIDRs = []string{
-	"0.0.0.0/8",
-	"10.0.0.0/8",
-	"100.64.0.0/10",
-	"127.0.0.0/8",
-	"169.254.0.0/16",
-	"172.16.0.0/12",
-	"192.0.0.0/24",
-	"192.0.2.0/24",
-	"192.88.99.0/24",
-	"192.168.0.0/16",
-	"198.18.0.0/15",
-	"198.51.100.0/24",
-	"203.0.113.0/24",
-	"224.0.0.0/4",
-	"240.0.0.0/4",
-	"255.255.255.255/32",
-	"::/128",
-	"::1/128",
-	"fc00::/7",
-	"ff00::/8",
-	"fe80::/10",
+// lineError 表示 geoip 源文件中某一行的解析错误。
+type lineError struct {
+	path string // 源文件路径
+	line int    // 行号（从 1 开始）
+	text string // 原始行内容
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("%s:%d: %q: %v", e.path, e.line, e.text, e.err)
+}
+
+// reportLineErrors 汇总打印所有解析错误。
+// 在 -strict 模式下存在任何错误都会以非零状态退出；否则错误行被丢弃，仅打印警告。
+func reportLineErrors(lineErrs []*lineError) {
+	if len(lineErrs) == 0 {
+		return
+	}
+	if *strictMode {
+		fmt.Printf("Failed: %d invalid line(s) in geoip source files:\n", len(lineErrs))
+	} else {
+		fmt.Printf("Warning: %d invalid line(s) dropped from geoip source files:\n", len(lineErrs))
+	}
+	for _, lineErr := range lineErrs {
+		fmt.Println("  " + lineErr.Error())
+	}
+	if *strictMode {
+		os.Exit(1)
+	}
 }
 
 // getCidrPerFile 遍历 data 目录下的文件，并为每个文件读取其包含的 CIDR 规则。
-// 文件的基础名称（大写）作为键，存储在 dataDirMap 中。
-func getCidrPerFile(dataDirMap map[string][]*router.CIDR) error {
+// 文件的基础名称（大写）作为键，CIDR 存储在 dataDirMap 中，include 和排除指令存储在 directives 中。
+// 每个代码的源文件路径记录在 sources 中，无法解析的行追加到 lineErrs 中。
+func getCidrPerFile(dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective, sources map[string][]string, lineErrs *[]*lineError) error {
 	walkErr := filepath.Walk(*dataPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -55,12 +62,17 @@ func getCidrPerFile(dataDirMap map[string][]*router.CIDR) error {
 		// 文件名（不含扩展名，转大写）作为国家/地区代码
 		onlyFileName := strings.ToUpper(strings.TrimSuffix(filename, fileExt))
 		cidrContainer := make([]*router.CIDR, 0)
+		directive := &geoipDirective{path: path}
 
 		// 读取文件内容并解析为 CIDR 列表
-		if err = readFileLineByLine(path, &cidrContainer); err != nil {
+		if err = readFileLineByLine(path, &cidrContainer, directive, lineErrs); err != nil {
 			return err
 		}
 		dataDirMap[onlyFileName] = cidrContainer
+		sources[onlyFileName] = []string{path}
+		if directive.hasDirective() {
+			directives[onlyFileName] = directive
+		}
 		return nil
 	})
 
@@ -70,58 +82,276 @@ func getCidrPerFile(dataDirMap map[string][]*router.CIDR) error {
 	return nil
 }
 
-// readFileLineByLine 按行读取指定路径的文件，将每行解析为一个 router.CIDR 并追加到 container 中。
-func readFileLineByLine(path string, container *[]*router.CIDR) error {
-	var cidrStr []string
+// parseCIDRLine 解析源文件中的一行，可以是单个地址、CIDR 或 IP 范围（start-end）。
+// 返回的 changed 表示该行在规范化时被修改（见 parseCanonicalIP）。
+func parseCIDRLine(line string) (cidrs []*router.CIDR, changed bool, err error) {
+	// IP 范围（如 "1.0.1.0-1.0.3.255"）展开为多个 CIDR
+	if strings.Contains(line, "-") {
+		return parseCanonicalIPRange(line)
+	}
+
+	cidr, changed, err := parseCanonicalIP(line)
+	if err != nil {
+		return nil, false, err
+	}
+	return []*router.CIDR{cidr}, changed, nil
+}
+
+// readFileLineByLine 按行读取指定路径的文件，将每行解析为 router.CIDR 并追加到 container 中。
+// include 和排除指令记录到 directive 中（见 geoipDirective）。
+// 空行和 # 注释被忽略；无法解析的行不会写入 container，而是带行号追加到 lineErrs 中。
+func readFileLineByLine(path string, container *[]*router.CIDR, directive *geoipDirective, lineErrs *[]*lineError) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	li := bufio.NewReader(file)
-	for {
-		a, _, c := li.ReadLine()
-		if c == io.EOF {
-			break
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		// 移除注释和空行
+		line := strings.TrimSpace(removeComment(scanner.Text()))
+		if isEmpty(line) {
+			continue
+		}
+
+		// include 指令，例如 "include:private"
+		if code, ok := parseGeoIPInclusion(line); ok {
+			if code == "" {
+				*lineErrs = append(*lineErrs, &lineError{path: path, line: lineNum, text: line, err: errors.New("empty include target")})
+				continue
+			}
+			directive.includes = append(directive.includes, code)
+			continue
 		}
-		// 收集所有非 EOF 行的字符串
-		cidrStr = append(cidrStr, string(a))
-	}
 
-	// 遍历收集到的字符串，解析为 router.CIDR 结构
-	for _, cidr := range cidrStr {
-		cidr1, err := ParseIP(cidr)
+		// reverse 指令，例如 "reverse" 或 "reverse:not-cn"
+		if parseGeoIPReverse(line, directive) {
+			continue
+		}
+
+		// 排除指令，例如 "!1.2.3.0/24" 或 "exclude:1.2.3.0/24"
+		value, excluded := trimExclusion(line)
+		cidrs, changed, err := parseCIDRLine(value)
 		if err != nil {
-			fmt.Println(err) // 打印错误，但不终止流程
+			*lineErrs = append(*lineErrs, &lineError{path: path, line: lineNum, text: line, err: err})
+			continue
+		}
+		if changed && *warnCanonical {
+			fmt.Printf("Warning: %s:%d: %q canonicalized to %s\n", path, lineNum, line, formatCIDRs(cidrs))
+		}
+		if excluded {
+			directive.excludes = append(directive.excludes, cidrs...)
+		} else {
+			*container = append(*container, cidrs...)
 		}
-		*container = append(*container, cidr1)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// private 将所有私有 CIDR 范围添加到 dataDirMap 中，使用 "PRIVATE" 作为键。
-func private(dataDirMap map[string][]*router.CIDR) {
-	container1 := make([]*router.CIDR, 0)
-	for _, cidr := range privateCIDRs {
-		cidr1, err := ParseIP(cidr)
-		if err != nil {
-			fmt.Println(err) // 打印错误，但不终止流程
+// splitFamilyEntries 为每个非反向匹配的代码生成仅包含 IPv4（代码 + -ipv4suffix）
+// 和仅包含 IPv6（代码 + -ipv6suffix）的条目。生成的代码不能与已有代码重名。
+func splitFamilyEntries(dataDirMap map[string][]*router.CIDR, reverseCodes map[string]bool, reverseAs map[string]string) ([]*router.GeoIP, error) {
+	v4Suffix := strings.ToUpper(*ipv4Suffix)
+	v6Suffix := strings.ToUpper(*ipv6Suffix)
+	if v4Suffix == "" || v6Suffix == "" || v4Suffix == v6Suffix {
+		return nil, errors.New("-ipv4suffix and -ipv6suffix must be non-empty and different")
+	}
+
+	entries := make([]*router.GeoIP, 0, 2*len(dataDirMap))
+	for cc, cidr := range dataDirMap {
+		if reverseCodes[cc] {
+			continue
+		}
+		ipv4, ipv6 := splitCIDRsByFamily(cidr)
+		for _, variant := range []struct {
+			code string
+			cidr []*router.CIDR
+		}{{cc + v4Suffix, ipv4}, {cc + v6Suffix, ipv6}} {
+			_, existing := dataDirMap[variant.code]
+			_, reversed := reverseAs[variant.code]
+			if existing || reversed {
+				return nil, fmt.Errorf("%s variant of %s conflicts with an existing code", variant.code, cc)
+			}
+			entries = append(entries, &router.GeoIP{
+				CountryCode: variant.code,
+				Cidr:        variant.cidr,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// hasExtraGeoIPInput 检查是否指定了 data 目录以外的 geoip 数据源。
+func hasExtraGeoIPInput(config *Config) bool {
+	if len(config.GeoIP.Cloud) > 0 || len(config.GeoIP.Extract) > 0 {
+		return true
+	}
+	for _, input := range []string{*maxmindCSVPath, *mmdbPath, *rirPath, *ip2locationPath, *dbipPath, *ip2asnPath, *pfx2asPath, *mrtPath} {
+		if input != "" {
+			return true
 		}
-		container1 = append(container1, cidr1)
 	}
-	dataDirMap["PRIVATE"] = container1
+	return false
 }
 
-// geoip 是生成 geoip.dat 文件的核心函数。
-func geoip() {
+// geoip 是生成 geoip.dat 文件的核心函数，config 为 custom.toml 中的配置。
+func geoip(config *Config) {
 	cidrList := make(map[string][]*router.CIDR)
-	private(cidrList) // 首先添加私有 IP 范围
+	// 首先添加 PRIVATE、RESERVED 等内置代码
+	if err := specialPurpose(cidrList); err != nil {
+		fmt.Println("Failed:", err)
+		os.Exit(1)
+	}
 
 	// 读取 data 目录下的文件，收集所有 CIDR 规则
-	if err := getCidrPerFile(cidrList); err != nil {
-		fmt.Println("Error looping data directory:", err)
+	// 若使用了其他数据源且 data 目录不存在，则跳过
+	directives := make(map[string]*geoipDirective)
+	sources := make(map[string][]string) // 国家/地区级代码的来源，用于重叠检查
+	if _, err := os.Stat(*dataPath); err == nil || !hasExtraGeoIPInput(config) {
+		var lineErrs []*lineError
+		if err := getCidrPerFile(cidrList, directives, sources, &lineErrs); err != nil {
+			fmt.Println("Error looping data directory:", err)
+			os.Exit(1)
+		}
+		reportLineErrors(lineErrs)
+	}
+	sizes := codeSizes(cidrList)
+
+	// 读取 GeoLite2 Country CSV 数据
+	if *maxmindCSVPath != "" {
+		if err := getCidrFromMaxMindCSV(*maxmindCSVPath, cidrList); err != nil {
+			fmt.Println("Error reading GeoLite2 CSV:", err)
+			os.Exit(1)
+		}
+		recordSources(cidrList, sizes, *maxmindCSVPath, sources)
+	}
+
+	// 读取 MMDB 数据库
+	if *mmdbPath != "" {
+		if err := getCidrFromMMDB(*mmdbPath, cidrList); err != nil {
+			fmt.Println("Error reading MMDB:", err)
+			os.Exit(1)
+		}
+		recordSources(cidrList, sizes, *mmdbPath, sources)
+	}
+
+	// 读取 RIR delegated 统计文件
+	if *rirPath != "" {
+		if err := getCidrFromRIR(*rirPath, cidrList); err != nil {
+			fmt.Println("Error reading RIR stats:", err)
+			os.Exit(1)
+		}
+		recordSources(cidrList, sizes, *rirPath, sources)
+	}
+
+	// 读取 IP2Location LITE 和 DB-IP lite 范围 CSV
+	if *ip2locationPath != "" {
+		if err := getCidrFromRangeCSV(*ip2locationPath, cidrList); err != nil {
+			fmt.Println("Error reading IP2Location CSV:", err)
+			os.Exit(1)
+		}
+		recordSources(cidrList, sizes, *ip2locationPath, sources)
+	}
+	if *dbipPath != "" {
+		if err := getCidrFromRangeCSV(*dbipPath, cidrList); err != nil {
+			fmt.Println("Error reading DB-IP CSV:", err)
+			os.Exit(1)
+		}
+		recordSources(cidrList, sizes, *dbipPath, sources)
+	}
+
+	// 读取 IPtoASN 和 CAIDA pfx2as 表，生成 ASN 代码
+	// ASN 代码不能与以上数据源中的代码重名
+	if *ip2asnPath != "" || *pfx2asPath != "" || *mrtPath != "" {
+		if err := checkASNCodes(config, cidrList); err != nil {
+			fmt.Println("Failed:", err)
+			os.Exit(1)
+		}
+	}
+	if *ip2asnPath != "" {
+		if err := getCidrFromASNTable(*ip2asnPath, false, config, cidrList); err != nil {
+			fmt.Println("Error reading ip2asn table:", err)
+			os.Exit(1)
+		}
+	}
+	if *pfx2asPath != "" {
+		if err := getCidrFromASNTable(*pfx2asPath, true, config, cidrList); err != nil {
+			fmt.Println("Error reading pfx2as table:", err)
+			os.Exit(1)
+		}
+	}
+
+	// 读取 MRT RIB 快照，按起源 ASN 生成 ASN 代码
+	if *mrtPath != "" {
+		if err := getCidrFromMRT(*mrtPath, config, cidrList); err != nil {
+			fmt.Println("Error reading MRT dump:", err)
+			os.Exit(1)
+		}
+	}
+
+	// 读取 custom.toml 中配置的云服务商 IP 范围
+	if err := getCidrFromCloud(config.GeoIP.Cloud, cidrList); err != nil {
+		fmt.Println("Error reading cloud IP ranges:", err)
+		os.Exit(1)
+	}
+
+	// 读取 custom.toml 中配置的通用 JSON/CSV 数据源
+	if err := getCidrFromExtract(config.GeoIP.Extract, cidrList); err != nil {
+		fmt.Println("Error extracting CIDRs:", err)
+		os.Exit(1)
+	}
+
+	// 检查国家/地区级代码之间的重叠，并按优先级生成互斥的代码
+	// （在处理 include 指令和分组之前，使它们与互斥的代码保持一致）
+	if err := resolveOverlaps(config, cidrList, directives, sources); err != nil {
+		fmt.Println("Failed:", err)
+		os.Exit(1)
+	}
+
+	// 添加大洲和欧盟分组（开启 builtingroups 时）以及 custom.toml 中定义的分组，分组以 include 指令的形式加入 directives
+	if err := addGeoIPGroups(config, cidrList, directives); err != nil {
+		fmt.Println("Failed:", err)
+		os.Exit(1)
+	}
+
+	// 在所有数据源读取完成后处理 include 和排除指令，使其可以引用任意数据源中的代码以及分组
+	if err := resolveGeoIPDirectives(cidrList, directives); err != nil {
+		fmt.Println("Failed:", err)
+		os.Exit(1)
+	}
+
+	// 计算 custom.toml 中定义的集合表达式
+	if err := addGeoIPSets(config, cidrList, directives); err != nil {
+		fmt.Println("Failed:", err)
+		os.Exit(1)
+	}
+
+	// 按国家/地区代码聚合 CIDR，移除重复、被覆盖的前缀并合并相邻前缀
+	for cc, cidr := range cidrList {
+		aggregated, err := aggregateCIDRs(cidr)
+		if err != nil {
+			fmt.Printf("Error aggregating %s: %v\n", cc, err)
+			os.Exit(1)
+		}
+		if len(aggregated) < len(cidr) {
+			// 只报告实际被合并的代码
+			fmt.Printf("%s: %d CIDRs aggregated into %d\n", cc, len(cidr), len(aggregated))
+		}
+		cidrList[cc] = aggregated
+	}
+
+	// 汇总 reverse 指令
+	reverseCodes, reverseAs, err := collectReverseCodes(cidrList, directives)
+	if err != nil {
+		fmt.Println("Failed:", err)
 		os.Exit(1)
 	}
 
@@ -129,8 +359,26 @@ func geoip() {
 	// 将 map 中的数据转换为 router.GeoIPList 结构
 	for cc, cidr := range cidrList {
 		geoIPList.Entry = append(geoIPList.Entry, &router.GeoIP{
-			CountryCode: cc, // 使用文件名（大写）作为国家/地区代码
-			Cidr:        cidr,
+			CountryCode:  cc, // 使用文件名（大写）作为国家/地区代码
+			Cidr:         cidr,
+			ReverseMatch: reverseCodes[cc],
+		})
+	}
+	// 为每个代码额外输出仅包含 IPv4 和仅包含 IPv6 的条目（反向匹配条目除外）
+	if *splitFamily {
+		familyEntries, err := splitFamilyEntries(cidrList, reverseCodes, reverseAs)
+		if err != nil {
+			fmt.Println("Failed:", err)
+			os.Exit(1)
+		}
+		geoIPList.Entry = append(geoIPList.Entry, familyEntries...)
+	}
+	// 额外的反向匹配条目与源代码共享相同的 CIDR 列表
+	for name, cc := range reverseAs {
+		geoIPList.Entry = append(geoIPList.Entry, &router.GeoIP{
+			CountryCode:  name,
+			Cidr:         cidrList[cc],
+			ReverseMatch: true,
 		})
 	}
 
@@ -156,4 +404,15 @@ func geoip() {
 	} else {
 		fmt.Println(*datName, "has been generated successfully.")
 	}
+
+	// 使用相同的数据写入 MMDB 文件，保证两种格式内容一致
+	// MMDB 的每个网段只能对应一个代码，因此只写入国家/地区级代码（见 mmdbCountryCodes）
+	if *mmdbName != "" {
+		mmdbList := mmdbCountryCodes(cidrList, sources, directives, reverseCodes)
+		if err := writeMMDB(mmdbList, filepath.Join(*outputPath, *mmdbName)); err != nil {
+			fmt.Println("Error writing MMDB:", err)
+			os.Exit(1)
+		}
+		fmt.Println(*mmdbName, "has been generated successfully.")
+	}
 }
@@ -0,0 +1,66 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+func TestReadFileLineByLineRanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		line         string
+		wantCIDRs    []string
+		wantExcludes []string
+		wantErr      bool
+	}{
+		{name: "ipv4 range", line: "1.0.1.0-1.0.2.255", wantCIDRs: []string{"1.0.1.0/24", "1.0.2.0/24"}},
+		{name: "ipv6 range", line: "2001:db8::-2001:db8::ffff", wantCIDRs: []string{"2001:db8::/112"}},
+		{name: "mapped endpoints", line: "::ffff:1.0.4.0-::ffff:1.0.5.255", wantCIDRs: []string{"1.0.4.0/23"}},
+		{name: "range with comment", line: "1.0.1.0-1.0.1.255 # cn", wantCIDRs: []string{"1.0.1.0/24"}},
+		{name: "excluded range", line: "!1.0.2.0-1.0.2.127", wantExcludes: []string{"1.0.2.0/25"}},
+		{name: "excluded ipv6 range", line: "exclude:2001:db8::100-2001:db8::1ff", wantExcludes: []string{"2001:db8::100/120"}},
+		{name: "mixed families", line: "1.0.0.0-2001:db8::1", wantErr: true},
+		{name: "reversed range", line: "1.0.2.255-1.0.1.0", wantErr: true},
+		{name: "reversed excluded range", line: "!1.0.2.255-1.0.1.0", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cn.txt")
+			if err := os.WriteFile(path, []byte(test.line+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var container []*router.CIDR
+			directive := &geoipDirective{path: path}
+			var lineErrs []*lineError
+			if err := readFileLineByLine(path, &container, directive, &lineErrs); err != nil {
+				t.Fatal(err)
+			}
+
+			if (len(lineErrs) > 0) != test.wantErr {
+				t.Fatalf("got errors %v, want error %v", lineErrs, test.wantErr)
+			}
+			if test.wantErr {
+				if len(container)+len(directive.excludes) > 0 {
+					t.Errorf("invalid line was kept: %v %v", prefixStrings(t, container), prefixStrings(t, directive.excludes))
+				}
+				return
+			}
+			if got := prefixStrings(t, container); !sameStrings(got, test.wantCIDRs) {
+				t.Errorf("got CIDRs %v, want %v", got, test.wantCIDRs)
+			}
+			if got := prefixStrings(t, directive.excludes); !sameStrings(got, test.wantExcludes) {
+				t.Errorf("got excludes %v, want %v", got, test.wantExcludes)
+			}
+		})
+	}
+}
+
+// sameStrings 比较两个字符串列表，nil 与空列表视为相同。
+func sameStrings(a, b []string) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
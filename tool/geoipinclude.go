@@ -0,0 +1,172 @@
+package tool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// geoipDirective 保存 geoip 源文件中的 include 和排除指令。
+//
+// 源文件中支持以下写法：
+//   - include:private         包含另一个代码的全部 CIDR（在其自身的指令处理完成之后）
+//   - !1.2.3.0/24             从结果中排除该网段，也可以写作 exclude:1.2.3.0/24
+//   - reverse                 将当前代码输出为反向匹配（ReverseMatch）条目
+//   - reverse:not-cn          额外输出一个名为 NOT-CN 的反向匹配条目，当前代码保持正常输出
+//
+// 排除在包含之后进行，因此可以排除从其他代码包含进来的网段。
+//
+// 使用 reverse 的代码的 CIDR 仍是其正向的网段，只是输出时匹配其补集，因此它不能作为其他代码的操作数：
+// include 指令、分组成员、集合表达式以及 priority 引用反向匹配的代码时返回错误（内置分组跳过这些成员）。
+// 需要补集时，可以在集合表达式中使用 !CN，或使用 reverse:not-cn 额外输出反向匹配条目。
+type geoipDirective struct {
+	path      string         // 源文件路径，用于错误提示
+	includes  []string       // 包含的代码（大写）
+	excludes  []*router.CIDR // 需要排除的网段
+	reverse   bool           // 当前代码是否输出为反向匹配条目
+	reverseAs []string       // 额外输出的反向匹配代码（大写）
+}
+
+// hasDirective 检查源文件中是否存在任何指令。
+func (d *geoipDirective) hasDirective() bool {
+	return len(d.includes) > 0 || len(d.excludes) > 0 || d.reverse || len(d.reverseAs) > 0
+}
+
+// parseGeoIPReverse 检查一行是否为 reverse 指令，并将其记录到 directive 中。
+func parseGeoIPReverse(line string, directive *geoipDirective) bool {
+	lower := strings.ToLower(line)
+	switch {
+	case lower == "reverse":
+		directive.reverse = true
+	case strings.HasPrefix(lower, "reverse:"):
+		if code := strings.ToUpper(strings.TrimSpace(line[len("reverse:"):])); code != "" {
+			directive.reverseAs = append(directive.reverseAs, code)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// isReverseCode 检查代码是否使用了 reverse 指令（输出为反向匹配条目）。
+func isReverseCode(directives map[string]*geoipDirective, code string) bool {
+	directive := directives[code]
+	return directive != nil && directive.reverse
+}
+
+// collectReverseCodes 汇总所有 reverse 指令。
+// 返回需要输出为反向匹配的代码集合，以及额外反向匹配代码到其源代码的映射。
+// 额外的反向匹配代码不能与已有代码重名。
+func collectReverseCodes(dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective) (map[string]bool, map[string]string, error) {
+	reverseCodes := make(map[string]bool)
+	reverseAs := make(map[string]string)
+	for code, directive := range directives {
+		if directive.reverse {
+			reverseCodes[code] = true
+		}
+		for _, name := range directive.reverseAs {
+			if _, found := dataDirMap[name]; found {
+				return nil, nil, fmt.Errorf("%s: reverse:%s conflicts with an existing code", directive.path, strings.ToLower(name))
+			}
+			if other, found := reverseAs[name]; found && other != code {
+				return nil, nil, fmt.Errorf("%s: reverse:%s is already defined by %s", directive.path, strings.ToLower(name), other)
+			}
+			reverseAs[name] = code
+		}
+	}
+	return reverseCodes, reverseAs, nil
+}
+
+// parseGeoIPInclusion 检查一行是否为 include 指令（如 "include:private"），并返回被包含的代码（大写）。
+func parseGeoIPInclusion(line string) (code string, ok bool) {
+	if !strings.HasPrefix(strings.ToLower(line), "include:") {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimSpace(line[len("include:"):])), true
+}
+
+// trimExclusion 检查一行是否为排除指令（如 "!1.2.3.0/24" 或 "exclude:1.2.3.0/24"），并返回去除前缀后的内容。
+func trimExclusion(line string) (value string, ok bool) {
+	switch {
+	case strings.HasPrefix(line, "!"):
+		return strings.TrimSpace(line[1:]), true
+	case strings.HasPrefix(strings.ToLower(line), "exclude:"):
+		return strings.TrimSpace(line[len("exclude:"):]), true
+	}
+	return line, false
+}
+
+// resolveGeoIPDirectives 按依赖顺序处理所有代码的 include 和排除指令，并将结果写回 dataDirMap。
+// 被包含的代码总是先于包含它的代码处理；存在循环包含、包含了不存在的代码或反向匹配的代码时返回错误。
+func resolveGeoIPDirectives(dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective) error {
+	walker := newDependencyWalker[string]("include")
+
+	var resolve func(code string) error
+	resolve = func(code string) error {
+		if done, err := walker.enter(code); done || err != nil {
+			return err
+		}
+
+		directive := directives[code]
+		if directive == nil || len(directive.includes)+len(directive.excludes) == 0 {
+			walker.leave()
+			return nil
+		}
+
+		builder, err := cidrsToIPSetBuilder(dataDirMap[code])
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		for _, included := range directive.includes {
+			if _, found := dataDirMap[included]; !found {
+				return fmt.Errorf("%s: include:%s refers to an unknown code", directive.path, strings.ToLower(included))
+			}
+			if isReverseCode(directives, included) {
+				return fmt.Errorf("%s: include:%s refers to a reverse code", directive.path, strings.ToLower(included))
+			}
+			if err := resolve(included); err != nil {
+				return err
+			}
+			includedBuilder, err := cidrsToIPSetBuilder(dataDirMap[included])
+			if err != nil {
+				return fmt.Errorf("%s: %w", included, err)
+			}
+			includedSet, err := includedBuilder.IPSet()
+			if err != nil {
+				return fmt.Errorf("%s: %w", included, err)
+			}
+			builder.AddSet(includedSet)
+		}
+		for _, excluded := range directive.excludes {
+			prefix, err := cidrToPrefix(excluded)
+			if err != nil {
+				return fmt.Errorf("%s: %w", code, err)
+			}
+			builder.RemovePrefix(prefix)
+		}
+
+		set, err := builder.IPSet()
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		dataDirMap[code] = ipSetToCIDRs(set)
+
+		walker.leave()
+		return nil
+	}
+
+	// 按代码排序处理，保证错误信息稳定
+	codes := make([]string, 0, len(directives))
+	for code := range directives {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if err := resolve(code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
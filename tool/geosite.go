@@ -2,17 +2,52 @@ package tool
 
 import (
 	"fmt"
-
 	"os"
 	"path/filepath"
+	"strings"
 
 	"google.golang.org/protobuf/proto"
 )
 
+// reportRuleErrors 汇总打印所有源文件中无法解析的规则，存在任何错误时以非零状态退出。
+func reportRuleErrors(ruleErrs []*ruleError) {
+	if len(ruleErrs) == 0 {
+		return
+	}
+	fmt.Printf("Failed: %d invalid rule(s) in geosite source files:\n", len(ruleErrs))
+	for _, ruleErr := range ruleErrs {
+		fmt.Println("  " + ruleErr.Error())
+	}
+	os.Exit(1)
+}
+
+// parseExcludeAttrs 解析 -excludeattrs 参数，返回每个列表需要排除的属性（不含 @ 前缀）。
+// 格式为逗号分隔的 "列表名@属性1@属性2"，例如 "google@cn@ads,apple@cn"。
+func parseExcludeAttrs(value string) map[fileName]map[attribute]bool {
+	excludeAttrsInFile := make(map[fileName]map[attribute]bool)
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), "@")
+		filename := fileName(strings.ToUpper(strings.TrimSpace(parts[0])))
+		if filename == "" {
+			continue
+		}
+		if excludeAttrsInFile[filename] == nil {
+			excludeAttrsInFile[filename] = make(map[attribute]bool)
+		}
+		for _, attr := range parts[1:] {
+			if attr = strings.ToLower(strings.TrimSpace(attr)); attr != "" {
+				excludeAttrsInFile[filename][attribute(attr)] = true
+			}
+		}
+	}
+	return excludeAttrsInFile
+}
+
 // geositeEntry 是生成 geosite.dat 文件的入口函数。
 func geositeEntry() {
 	dir := GetDataDir() // 获取数据目录路径
 	listInfoMap := make(ListInfoMap)
+	var ruleErrs []*ruleError
 
 	// 遍历数据目录下的所有文件
 	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -23,7 +58,7 @@ func geositeEntry() {
 			return nil // 跳过目录
 		}
 		// 处理单个文件，生成 ListInfo 并存入 listInfoMap
-		if err := listInfoMap.Marshal(path); err != nil {
+		if err := listInfoMap.Marshal(path, &ruleErrs); err != nil {
 			return err
 		}
 		return nil
@@ -31,6 +66,7 @@ func geositeEntry() {
 		fmt.Println("Failed:", err)
 		os.Exit(1)
 	}
+	reportRuleErrors(ruleErrs)
 
 	// 展平包含（include）的列表，并为 Domain 类型的规则生成唯一列表（去重）
 	if err := listInfoMap.FlattenAndGenUniqueDomainList(); err != nil {
@@ -38,8 +74,8 @@ func geositeEntry() {
 		os.Exit(1)
 	}
 
-	// 预留的排除属性映射，目前为空
-	excludeAttrsInFile := make(map[fileName]map[attribute]bool)
+	// 解析 -excludeattrs 指定的排除属性
+	excludeAttrsInFile := parseExcludeAttrs(*excludeAttrs)
 
 	// 将 ListInfoMap 转换为 router.GeoSiteList 结构
 	if geositeList := listInfoMap.ToProto(excludeAttrsInFile); geositeList != nil {
@@ -0,0 +1,99 @@
+package tool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// continentCountries 是大洲代码到国家/地区代码（ISO 3166-1 alpha-2）的对应表，划分方式与 GeoNames 一致。
+var continentCountries = map[string]string{
+	"AF": "AO BF BI BJ BW CD CF CG CI CM CV DJ DZ EG EH ER ET GA GH GM GN GQ GW KE KM LR LS LY MA MG ML MR " +
+		"MU MW MZ NA NE NG RE RW SC SD SH SL SN SO SS ST SZ TD TG TN TZ UG YT ZA ZM ZW",
+	"AN": "AQ BV GS HM TF",
+	"AS": "AE AF AM AZ BD BH BN BT CC CN CX GE HK ID IL IN IO IQ IR JO JP KG KH KP KR KW KZ LA LB LK MM MN " +
+		"MO MV MY NP OM PH PK PS QA SA SG SY TH TJ TM TR TW UZ VN YE",
+	"EU": "AD AL AT AX BA BE BG BY CH CY CZ DE DK EE ES FI FO FR GB GG GI GR HR HU IE IM IS IT JE LI LT LU " +
+		"LV MC MD ME MK MT NL NO PL PT RO RS RU SE SI SJ SK SM UA VA XK",
+	"NA": "AG AI AW BB BL BM BQ BS BZ CA CR CU CW DM DO GD GL GP GT HN HT JM KN KY LC MF MQ MS MX NI PA PM " +
+		"PR SV SX TC TT US VC VG VI",
+	"OC": "AS AU CK FJ FM GU KI MH MP NC NF NR NU NZ PF PG PN PW SB TK TL TO TV UM VU WF WS",
+	"SA": "AR BO BR CL CO EC FK GF GY PE PY SR UY VE",
+}
+
+// euMemberStates 是欧盟成员国的国家代码。
+const euMemberStates = "AT BE BG CY CZ DE DK EE ES FI FR GR HR HU IE IT LT LU LV MT NL PL PT RO SE SI SK"
+
+// continentPrefix 是大洲代码的前缀。大洲代码与部分国家代码相同（如 AS 美属萨摩亚、NA 纳米比亚、
+// SA 沙特阿拉伯），因此大洲代码输出为 CONTINENT-AS、CONTINENT-NA 等。
+const continentPrefix = "CONTINENT-"
+
+// builtinGroups 返回内置的分组：每个大洲（CONTINENT-<大洲代码>）以及欧盟成员国（EU）。
+// 内置分组与其成员代码完全重叠，会增大 geoip.dat（使用 -split 时还会再各生成两份），
+// 因此只在 [geoip] 中设置 builtingroups = true 时生成。
+func builtinGroups() map[string][]string {
+	groups := make(map[string][]string, len(continentCountries)+1)
+	for continent, countries := range continentCountries {
+		groups[continentPrefix+continent] = strings.Fields(countries)
+	}
+	groups["EU"] = strings.Fields(euMemberStates)
+	return groups
+}
+
+// addGeoIPGroups 为内置分组（需要开启 builtingroups）和 custom.toml 中 [geoip.groups] 定义的分组生成代码，
+// 分组的 CIDR 是其所有成员代码的并集。分组以 include 指令的形式加入 directives，
+// 由 resolveGeoIPDirectives 按依赖顺序计算，因此分组可以引用其他分组，源文件也可以包含分组。
+// 分组与其成员代码重叠，因此只输出到 geoip.dat，不写入 MMDB（见 mmdbCountryCodes）。
+//
+//   - 内置分组只包含实际存在且不是反向匹配的成员；若分组名与已有代码重名则跳过
+//   - 自定义分组与内置分组重名时替换内置分组；成员必须是已有代码或其他分组，分组名不能与已有代码重名
+func addGeoIPGroups(config *Config, dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective) error {
+	groups := make(map[string][]string)
+	if config.GeoIP.BuiltinGroups {
+		groups = builtinGroups()
+	}
+	custom := make(map[string]bool, len(config.GeoIP.Groups))
+	for name, members := range config.GeoIP.Groups {
+		code := strings.ToUpper(strings.TrimSpace(name))
+		groups[code] = members
+		custom[code] = true
+	}
+
+	// 按分组名排序，保证输出稳定
+	codes := make([]string, 0, len(groups))
+	for code := range groups {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		if _, found := dataDirMap[code]; found {
+			if custom[code] {
+				return fmt.Errorf("group %s conflicts with an existing code", code)
+			}
+			fmt.Printf("Skipped group %s: code already exists\n", code)
+			continue
+		}
+
+		directive := &geoipDirective{path: fmt.Sprintf("%s [geoip.groups.%s]", *customPath, strings.ToLower(code))}
+		for _, member := range groups[code] {
+			member = strings.ToUpper(strings.TrimSpace(member))
+			if !custom[code] {
+				// 内置分组忽略不存在的成员和反向匹配的成员
+				if _, found := dataDirMap[member]; !found || isReverseCode(directives, member) {
+					continue
+				}
+			}
+			directive.includes = append(directive.includes, member)
+		}
+		if len(directive.includes) == 0 {
+			continue
+		}
+
+		dataDirMap[code] = []*router.CIDR{}
+		directives[code] = directive
+	}
+	return nil
+}
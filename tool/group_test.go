@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+func TestGeoIPGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		builtin bool
+		groups  map[string][]string
+		want    map[string][]string // 分组代码 -> CIDR
+		wantErr bool
+	}{
+		{
+			name:    "builtin",
+			builtin: true,
+			want: map[string][]string{
+				"CONTINENT-AS": {"1.0.1.0/24", "1.0.2.0/24"},
+				"CONTINENT-NA": {"8.8.8.0/24"},
+				"CONTINENT-EU": {"5.0.0.0/24"},
+				"EU":           {"5.0.0.0/24"},
+			},
+		},
+		{
+			name:    "custom group of groups",
+			builtin: true,
+			groups:  map[string][]string{"greater-cn": {"CN", "hk"}, "asia-us": {"continent-as", "US"}},
+			want: map[string][]string{
+				"GREATER-CN": {"1.0.1.0/24", "1.0.2.0/24"},
+				"ASIA-US":    {"1.0.1.0/24", "1.0.2.0/24", "8.8.8.0/24"},
+			},
+		},
+		{
+			name:    "builtin groups are off by default",
+			groups:  map[string][]string{"asia-us": {"continent-as", "US"}},
+			wantErr: true,
+		},
+		{
+			name:    "custom group conflicts with a code",
+			groups:  map[string][]string{"cn": {"HK"}},
+			wantErr: true,
+		},
+		{
+			name:    "custom group with unknown member",
+			groups:  map[string][]string{"g": {"CN", "XX"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cidrList := testCIDRList(t, nil)
+			directives := make(map[string]*geoipDirective)
+			config := &Config{}
+			config.GeoIP.BuiltinGroups = test.builtin
+			config.GeoIP.Groups = test.groups
+
+			err := addGeoIPGroups(config, cidrList, directives)
+			if err == nil {
+				err = resolveGeoIPDirectives(cidrList, directives)
+			}
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			checkCIDRs(t, cidrList, test.want)
+		})
+	}
+}
+
+func TestGeoIPGroupsNotWrittenToMMDB(t *testing.T) {
+	cidrList := testCIDRList(t, map[string][]string{"SA": {"2.0.0.0/24"}})
+	sources := map[string][]string{"CN": {"cn.txt"}, "HK": {"hk.txt"}, "US": {"us.txt"}, "DE": {"de.txt"}, "SA": {"sa.txt"}}
+	directives := make(map[string]*geoipDirective)
+	config := &Config{}
+	config.GeoIP.BuiltinGroups = true
+	if err := addGeoIPGroups(config, cidrList, directives); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolveGeoIPDirectives(cidrList, directives); err != nil {
+		t.Fatal(err)
+	}
+
+	mmdbList := mmdbCountryCodes(cidrList, sources, directives, nil)
+	for code := range mmdbList {
+		if _, found := sources[code]; !found {
+			t.Errorf("%s should not be written to the MMDB", code)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "Country.mmdb")
+	if err := writeMMDB(mmdbList, path); err != nil {
+		t.Fatal(err)
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for ip, want := range map[string]string{"1.0.1.1": "CN", "2.0.0.1": "SA", "5.0.0.1": "DE", "8.8.8.8": "US"} {
+		var record mmdbCountryRecord
+		if err := db.Lookup(net.ParseIP(ip), &record); err != nil {
+			t.Fatal(err)
+		}
+		if got := record.isoCode(); got != want {
+			t.Errorf("%s: got %q, want %q", ip, got, want)
+		}
+	}
+}
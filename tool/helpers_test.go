@@ -0,0 +1,76 @@
+package tool
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// mustCIDRs 将 CIDR 字符串解析为 router.CIDR 列表。
+func mustCIDRs(t *testing.T, strs ...string) []*router.CIDR {
+	t.Helper()
+	cidrs := make([]*router.CIDR, 0, len(strs))
+	for _, str := range strs {
+		cidr, err := ParseIP(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// prefixStrings 将 CIDR 列表转换为字符串形式（保持原有顺序）。
+func prefixStrings(t *testing.T, cidrs []*router.CIDR) []string {
+	t.Helper()
+	strs := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := cidrToPrefix(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		strs = append(strs, prefix.String())
+	}
+	return strs
+}
+
+// cidrStrings 将 dataDirMap 转换为代码到 CIDR 字符串（排序后）的映射，便于比较。
+func cidrStrings(t *testing.T, dataDirMap map[string][]*router.CIDR) map[string][]string {
+	t.Helper()
+	result := make(map[string][]string, len(dataDirMap))
+	for code, cidrs := range dataDirMap {
+		strs := prefixStrings(t, cidrs)
+		sort.Strings(strs)
+		result[code] = strs
+	}
+	return result
+}
+
+// testCIDRList 返回测试通用的代码：CN 1.0.1.0/24、HK 1.0.2.0/24、US 8.8.8.0/24、DE 5.0.0.0/24。
+// codes 中的代码会添加到结果中，或替换同名的代码。
+func testCIDRList(t *testing.T, codes map[string][]string) map[string][]*router.CIDR {
+	t.Helper()
+	cidrList := map[string][]*router.CIDR{
+		"CN": mustCIDRs(t, "1.0.1.0/24"),
+		"HK": mustCIDRs(t, "1.0.2.0/24"),
+		"US": mustCIDRs(t, "8.8.8.0/24"),
+		"DE": mustCIDRs(t, "5.0.0.0/24"),
+	}
+	for code, strs := range codes {
+		cidrList[code] = mustCIDRs(t, strs...)
+	}
+	return cidrList
+}
+
+// checkCIDRs 检查 want 中列出的每个代码在 dataDirMap 中的 CIDR（排序后的字符串形式）。
+func checkCIDRs(t *testing.T, dataDirMap map[string][]*router.CIDR, want map[string][]string) {
+	t.Helper()
+	got := cidrStrings(t, dataDirMap)
+	for code, cidrs := range want {
+		if !reflect.DeepEqual(got[code], cidrs) {
+			t.Errorf("%s: got %v, want %v", code, got[code], cidrs)
+		}
+	}
+}
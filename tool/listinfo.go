@@ -3,10 +3,12 @@ package tool
 import (
 	"bufio"
 	"errors"
-
+	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	router "github.com/xtls/xray-core/app/router"
 )
@@ -14,24 +16,26 @@ import (
 // ListInfo 是数据目录下单个文件的信息结构。
 // 它包含文件中所有类型的规则，以及为了方便后续处理而存储的相同规则的多种结构。
 type ListInfo struct {
-	Name                    fileName                       // 列表文件名称 (e.g., CN)
-	HasInclusion            bool                           // 标记文件是否包含 `include:` 规则
-	InclusionAttributeMap   map[fileName][]attribute       // 包含的文件名及其需要的属性 (e.g., {"GOOGLE": ["@cn", "@gfw"]})
-	FullTypeList            []*router.Domain               // full 类型的规则列表
-	KeywordTypeList         []*router.Domain               // keyword (plain) 类型的规则列表
-	RegexpTypeList          []*router.Domain               // regexp 类型的规则列表
-	AttributeRuleUniqueList []*router.Domain               // 带有属性规则的列表 (未去重)
-	DomainTypeList          []*router.Domain               // domain 类型的规则列表
-	DomainTypeUniqueList    []*router.Domain               // domain 类型的规则去重后的列表
-	AttributeRuleListMap    map[attribute][]*router.Domain // 按属性分组的规则列表 (e.g., {"@cn": [...], "@ads": [...]})
-	GeoSite                 *router.GeoSite                // 最终生成的 GeoSite 结构
+	Name                    fileName                        // 列表文件名称 (e.g., CN)
+	Path                    string                          // 列表文件路径，用于错误提示
+	HasInclusion            bool                            // 标记文件是否包含 `include:` 规则
+	InclusionAttributeMap   map[fileName][]*attributeFilter // 包含的文件名及每条 include 规则的属性过滤条件 (e.g., {"GOOGLE": [@cn @!ads]})
+	FullTypeList            []*router.Domain                // full 类型的规则列表
+	KeywordTypeList         []*router.Domain                // keyword (plain) 类型的规则列表
+	RegexpTypeList          []*router.Domain                // regexp 类型的规则列表
+	AttributeRuleUniqueList []*router.Domain                // 带有属性规则的列表 (未去重)
+	DomainTypeList          []*router.Domain                // domain 类型的规则列表
+	DomainTypeUniqueList    []*router.Domain                // domain 类型的规则去重后的列表
+	AttributeRuleListMap    map[attribute][]*router.Domain  // 按属性分组的规则列表 (e.g., {"@cn": [...], "@ads": [...]})
+	ExclusionList           []*router.Domain                // 排除规则列表 (e.g., -domain:example.com, exclude:full:foo.bar)
+	GeoSite                 *router.GeoSite                 // 最终生成的 GeoSite 结构
 }
 
 // NewListInfo 返回一个初始化的 ListInfo 结构体。
 func NewListInfo() *ListInfo {
 	return &ListInfo{
 		// 初始化所有 map 和 slice
-		InclusionAttributeMap:   make(map[fileName][]attribute),
+		InclusionAttributeMap:   make(map[fileName][]*attributeFilter),
 		FullTypeList:            make([]*router.Domain, 0, 10),
 		KeywordTypeList:         make([]*router.Domain, 0, 10),
 		RegexpTypeList:          make([]*router.Domain, 0, 10),
@@ -42,31 +46,111 @@ func NewListInfo() *ListInfo {
 	}
 }
 
+// ruleError 表示 geosite 源文件中某条规则的解析错误。
+type ruleError struct {
+	path   string // 源文件路径
+	line   int    // 行号（从 1 开始）
+	column int    // 列号（从 1 开始，按字符计）
+	err    error
+}
+
+func (e *ruleError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.path, e.line, e.column, e.err)
+}
+
+// ruleToken 是规则行中由空白字符分隔的一个记号。
+type ruleToken struct {
+	text   string // 记号内容
+	column int    // 记号在行中的起始列（从 1 开始，按字符计）
+}
+
+// tokenError 返回指向记号中第 offset 个字节所在列的错误，文件和行号由 ProcessList 补充。
+func tokenError(token ruleToken, offset int, format string, args ...any) error {
+	return &ruleError{
+		column: token.column + utf8.RuneCountInString(token.text[:offset]),
+		err:    fmt.Errorf(format, args...),
+	}
+}
+
+// locateRuleError 为错误补充文件名和行号；不带列号的错误指向 token 的起始列。
+func locateRuleError(err error, token ruleToken, path string, line int) *ruleError {
+	var ruleErr *ruleError
+	if !errors.As(err, &ruleErr) {
+		ruleErr = &ruleError{column: token.column, err: err}
+	}
+	ruleErr.path, ruleErr.line = path, line
+	return ruleErr
+}
+
+// tokenizeRule 按空白字符（空格、制表符等，可以连续出现）将一行拆分为记号，并记录每个记号的起始列。
+func tokenizeRule(line string) []ruleToken {
+	var tokens []ruleToken
+	start, startColumn, column := -1, 0, 0
+	for idx, r := range line {
+		column++
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, ruleToken{text: line[start:idx], column: startColumn})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start, startColumn = idx, column
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, ruleToken{text: line[start:], column: startColumn})
+	}
+	return tokens
+}
+
 // ProcessList 逐行处理数据目录中单个文件，并生成该文件的 ListInfo。
-func (l *ListInfo) ProcessList(file *os.File) error {
+// 无法解析的规则不会写入 ListInfo，而是带文件名、行号和列号追加到 ruleErrs 中。
+func (l *ListInfo) ProcessList(file *os.File, ruleErrs *[]*ruleError) error {
 	scanner := bufio.NewScanner(file)
+	lineNum := 0
 	// 逐行解析文件以生成 ListInfo
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 
-		// 移除空行和注释
-		if isEmpty(line) {
-			continue
+		// 移除注释（从 # 字符开始），保留行首空白以便计算列号
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
 		}
-		line = removeComment(line)
-		if isEmpty(line) {
-			continue
+		tokens := tokenizeRule(line)
+		if len(tokens) == 0 {
+			continue // 空行
 		}
 
 		// 解析单条规则
-		parsedRule, err := l.parseRule(line)
+		parsedRule, err := l.parseRule(tokens)
 		if err != nil {
-			return err
+			*ruleErrs = append(*ruleErrs, locateRuleError(err, tokens[0], file.Name(), lineNum))
+			continue
 		}
 		if parsedRule == nil {
 			continue // 可能是 include 规则
 		}
 
+		// 使用与 xray 相同的正则表达式引擎检查 regexp 规则
+		if parsedRule.GetType() == router.Domain_Regex {
+			warning, err := checkRegexpRule(tokens[0], parsedRule.GetValue())
+			if err != nil {
+				ruleErr := locateRuleError(err, tokens[0], file.Name(), lineNum)
+				if !*laxRegexp {
+					*ruleErrs = append(*ruleErrs, ruleErr)
+					continue
+				}
+				fmt.Println("Warning:", ruleErr.Error(), "(rule dropped)")
+				continue
+			}
+			if warning != nil {
+				fmt.Println("Warning:", locateRuleError(warning, tokens[0], file.Name(), lineNum).Error())
+			}
+		}
+
 		// 对解析后的规则进行分类和存储
 		l.classifyRule(parsedRule)
 	}
@@ -77,106 +161,228 @@ func (l *ListInfo) ProcessList(file *os.File) error {
 	return nil
 }
 
-// parseRule 解析一行文本，将其转换为 router.Domain 规则结构。
-func (l *ListInfo) parseRule(line string) (*router.Domain, error) {
-	line = strings.TrimSpace(line)
-
-	if line == "" {
-		return nil, errors.New("empty line")
-	}
-
+// parseRule 将一行的记号转换为 router.Domain 规则结构。
+// 第一个记号是规则主体（如 domain:google.com），其余记号为属性（如 @cn）。
+func (l *ListInfo) parseRule(tokens []ruleToken) (*router.Domain, error) {
 	// 首先解析 `include` 规则，例如: `include:google`, `include:google @cn @gfw`
-	if strings.HasPrefix(line, "include:") {
-		l.parseInclusion(line)
-		return nil, nil // include 规则不返回 router.Domain
+	if strings.HasPrefix(strings.ToLower(tokens[0].text), "include:") {
+		return nil, l.parseInclusion(tokens) // include 规则不返回 router.Domain
 	}
 
-	// 解析非 include 规则
-	parts := strings.Split(line, " ")
-	ruleWithType := strings.TrimSpace(parts[0]) // 规则主体 (如 domain:google.com)
-	if ruleWithType == "" {
-		return nil, errors.New("empty rule")
+	// 解析排除规则，例如: `-domain:example.com`, `exclude:full:foo.bar`
+	if token, ok := trimRuleExclusion(tokens[0]); ok {
+		return nil, l.parseExclusion(token, tokens[1:]) // 排除规则不返回 router.Domain
 	}
 
 	var rule router.Domain
 	// 解析规则类型和值
-	if err := l.parseTypeRule(ruleWithType, &rule); err != nil {
+	if err := l.parseTypeRule(tokens[0], &rule); err != nil {
 		return nil, err
 	}
 
 	// 解析后续的属性 (attributes)
-	for _, attrString := range parts[1:] {
-		if attrString = strings.TrimSpace(attrString); attrString != "" {
-			attr, err := l.parseAttribute(attrString)
-			if err != nil {
-				return nil, err
-			}
-			rule.Attribute = append(rule.Attribute, attr)
+	for _, token := range tokens[1:] {
+		attr, err := l.parseAttribute(token)
+		if err != nil {
+			return nil, err
 		}
+		rule.Attribute = append(rule.Attribute, attr)
 	}
 
 	return &rule, nil
 }
 
+// trimRuleExclusion 检查规则主体是否带有排除前缀（"-" 或 "exclude:"），并返回去除前缀后的记号。
+func trimRuleExclusion(token ruleToken) (ruleToken, bool) {
+	prefixLen := 0
+	switch {
+	case strings.HasPrefix(token.text, "-"):
+		prefixLen = len("-")
+	case strings.HasPrefix(strings.ToLower(token.text), "exclude:"):
+		prefixLen = len("exclude:")
+	default:
+		return token, false
+	}
+	return ruleToken{text: token.text[prefixLen:], column: token.column + prefixLen}, true
+}
+
+// parseExclusion 解析排除规则并将其添加到 ListInfo 中。排除规则不能带有属性。
+func (l *ListInfo) parseExclusion(token ruleToken, attrs []ruleToken) error {
+	if token.text == "" {
+		return tokenError(token, 0, "empty exclusion")
+	}
+	if len(attrs) > 0 {
+		return tokenError(attrs[0], 0, "exclusion rules cannot have attributes")
+	}
+	var rule router.Domain
+	if err := l.parseTypeRule(token, &rule); err != nil {
+		return err
+	}
+	l.ExclusionList = append(l.ExclusionList, &rule)
+	return nil
+}
+
+// excludes 检查排除规则 exclusion 是否移除规则 rule：
+// domain 类型的排除规则移除相同的 domain 规则，以及值等于该域名或为其子域的 domain 和 full 规则；
+// 其他类型的排除规则只移除类型和值都相同的规则。
+func excludes(exclusion, rule *router.Domain) bool {
+	if exclusion.GetType() == router.Domain_Domain &&
+		(rule.GetType() == router.Domain_Domain || rule.GetType() == router.Domain_Full) {
+		return rule.GetValue() == exclusion.GetValue() || strings.HasSuffix(rule.GetValue(), "."+exclusion.GetValue())
+	}
+	return rule.GetType() == exclusion.GetType() && rule.GetValue() == exclusion.GetValue()
+}
+
+// applyExclusions 从 ListInfo 的所有规则（包括通过 include 包含的规则）中移除被排除规则覆盖的规则。
+func (l *ListInfo) applyExclusions() {
+	if len(l.ExclusionList) == 0 {
+		return
+	}
+	filter := func(rules []*router.Domain) []*router.Domain {
+		kept := make([]*router.Domain, 0, len(rules))
+	next:
+		for _, rule := range rules {
+			for _, exclusion := range l.ExclusionList {
+				if excludes(exclusion, rule) {
+					continue next
+				}
+			}
+			kept = append(kept, rule)
+		}
+		return kept
+	}
+
+	l.FullTypeList = filter(l.FullTypeList)
+	l.DomainTypeList = filter(l.DomainTypeList)
+	l.KeywordTypeList = filter(l.KeywordTypeList)
+	l.RegexpTypeList = filter(l.RegexpTypeList)
+	l.AttributeRuleUniqueList = filter(l.AttributeRuleUniqueList)
+	for attr, rules := range l.AttributeRuleListMap {
+		l.AttributeRuleListMap[attr] = filter(rules)
+	}
+}
+
+// attributeFilter 是一条 include 规则的属性过滤条件，语义与 domain-list-community 一致：
+//   - 带属性的规则必须具有所有 mustAttrs 且不具有任何 banAttrs 才会被包含
+//   - 不带属性的规则只在没有 mustAttrs 时被包含
+//
+// 例如 `include:google @!cn` 包含 GOOGLE 中所有不带 @cn 属性的规则（包括不带属性的规则），
+// `include:apple @cn @!ads` 只包含 APPLE 中带有 @cn 但不带 @ads 属性的规则。
+type attributeFilter struct {
+	mustAttrs []attribute // 必须具有的属性（不含 @ 前缀）
+	banAttrs  []attribute // 不能具有的属性（不含 @ 前缀）
+}
+
+// match 检查带属性的规则是否满足过滤条件。
+func (f *attributeFilter) match(rule *router.Domain) bool {
+	hasAttr := func(key attribute) bool {
+		for _, attr := range rule.GetAttribute() {
+			if attribute(attr.GetKey()) == key {
+				return true
+			}
+		}
+		return false
+	}
+	for _, attr := range f.mustAttrs {
+		if !hasAttr(attr) {
+			return false
+		}
+	}
+	for _, attr := range f.banAttrs {
+		if hasAttr(attr) {
+			return false
+		}
+	}
+	return true
+}
+
 // parseInclusion 解析 `include:` 规则，并将包含信息添加到 ListInfo 中。
-// 例如: `include:google @cn @gfw`
-func (l *ListInfo) parseInclusion(inclusion string) {
-	inclusionVal := strings.TrimPrefix(strings.TrimSpace(inclusion), "include:")
-	l.HasInclusion = true
-	inclusionValSlice := strings.Split(inclusionVal, "@")
+// 例如: `include:google @cn @gfw`、`include:google @!cn`、`include:apple @cn @!ads`
+// 兼容属性紧跟在列表名之后的旧写法，例如 `include:google@cn` 等同于 `include:google @cn`。
+func (l *ListInfo) parseInclusion(tokens []ruleToken) error {
+	target := tokens[0].text[len("include:"):]
+	attrTokens := tokens[1:]
+	if idx := strings.IndexByte(target, '@'); idx >= 0 {
+		// 将紧跟的属性拆分为单独的记号，列号（按字符计）指向各自的 "@"
+		column := tokens[0].column + len("include:") + utf8.RuneCountInString(target[:idx])
+		var attached []ruleToken
+		for _, part := range strings.Split(target[idx+1:], "@") {
+			attached = append(attached, ruleToken{text: "@" + part, column: column})
+			column += len("@") + utf8.RuneCountInString(part)
+		}
+		target = target[:idx]
+		attrTokens = append(attached, attrTokens...)
+	}
+	if target == "" {
+		return tokenError(tokens[0], len("include:"), "empty include target")
+	}
 	// 文件名转换为大写
-	filename := fileName(strings.ToUpper(strings.TrimSpace(inclusionValSlice[0])))
-
-	switch len(inclusionValSlice) {
-	case 1: // 没有属性的包含规则，例如: `include:google`
-		// 使用 '@' 作为占位符属性，表示包含该文件中的所有规则（无论有无属性）
-		l.InclusionAttributeMap[filename] = append(l.InclusionAttributeMap[filename], attribute("@"))
-	default: // 带有属性的包含规则，例如: `include:google @cn @gfw`
-		// 遍历所有属性
-		for _, attr := range inclusionValSlice[1:] {
-			attr = strings.ToLower(strings.TrimSpace(attr))
-			if attr != "" {
-				// 属性以 "@" 字符开头存储，例如: '@cn'
-				l.InclusionAttributeMap[filename] = append(l.InclusionAttributeMap[filename], attribute("@"+attr))
-			}
+	filename := fileName(strings.ToUpper(target))
+
+	// 先解析所有属性，出错时不记录该 include 规则
+	filter := new(attributeFilter)
+	for _, token := range attrTokens {
+		// "@!cn" 表示排除带有 @cn 属性的规则
+		negated := strings.HasPrefix(token.text, "@!")
+		if negated {
+			token = ruleToken{text: "@" + token.text[2:], column: token.column + 1}
+		}
+		attr, err := l.parseAttribute(token)
+		if err != nil {
+			return err
+		}
+		if negated {
+			filter.banAttrs = append(filter.banAttrs, attribute(attr.GetKey()))
+		} else {
+			filter.mustAttrs = append(filter.mustAttrs, attribute(attr.GetKey()))
 		}
 	}
+
+	l.HasInclusion = true
+	l.InclusionAttributeMap[filename] = append(l.InclusionAttributeMap[filename], filter)
+	return nil
 }
 
-// parseTypeRule 解析规则类型和值，例如 "domain:google.com" 或 "google.com"
-func (l *ListInfo) parseTypeRule(domain string, rule *router.Domain) error {
-	kv := strings.Split(domain, ":")
-	switch len(kv) {
-	case 1: // 没有类型前缀的行，默认视为 domain 类型
+// parseTypeRule 解析规则类型和值，例如 "domain:google.com" 或 "google.com"。
+// 只按第一个 ":" 拆分类型前缀，因此值中可以包含 ":"（如 "regexp:^foo:8080$"）。
+func (l *ListInfo) parseTypeRule(token ruleToken, rule *router.Domain) error {
+	ruleType, ruleVal, found := strings.Cut(token.text, ":")
+	if !found { // 没有类型前缀的行，默认视为 domain 类型
 		rule.Type = router.Domain_Domain
-		rule.Value = strings.ToLower(strings.TrimSpace(kv[0]))
-	case 2: // 带有类型前缀的行
-		ruleType := strings.TrimSpace(kv[0])
-		ruleVal := strings.TrimSpace(kv[1])
-		rule.Value = strings.ToLower(ruleVal) // 规则值转小写（regexp 除外）
-		switch strings.ToLower(ruleType) {
-		case "full":
-			rule.Type = router.Domain_Full
-		case "domain":
-			rule.Type = router.Domain_Domain
-		case "keyword":
-			rule.Type = router.Domain_Plain // Plain 对应 keyword
-		case "regexp":
-			rule.Type = router.Domain_Regex
-			rule.Value = ruleVal // 正则表达式规则值保留原始大小写
-		default:
-			return errors.New("unknown domain type: " + ruleType)
-		}
+		rule.Value = strings.ToLower(token.text)
+		return nil
+	}
+
+	// 带有类型前缀的行
+	rule.Value = strings.ToLower(ruleVal) // 规则值转小写（regexp 除外）
+	switch strings.ToLower(ruleType) {
+	case "full":
+		rule.Type = router.Domain_Full
+	case "domain":
+		rule.Type = router.Domain_Domain
+	case "keyword":
+		rule.Type = router.Domain_Plain // Plain 对应 keyword
+	case "regexp":
+		rule.Type = router.Domain_Regex
+		rule.Value = ruleVal // 正则表达式规则值保留原始大小写
+	default:
+		return tokenError(token, 0, "unknown domain type: %s", ruleType)
+	}
+	if ruleVal == "" {
+		return tokenError(token, len(ruleType)+1, "empty %s value", strings.ToLower(ruleType))
 	}
 	return nil
 }
 
-// parseAttribute 解析属性字符串（例如 "@cn"）并转换为 router.Domain_Attribute 结构。
-func (l *ListInfo) parseAttribute(attr string) (*router.Domain_Attribute, error) {
-	if attr[0] != '@' {
-		return nil, errors.New("invalid attribute: " + attr)
+// parseAttribute 解析属性记号（例如 "@cn"）并转换为 router.Domain_Attribute 结构。
+func (l *ListInfo) parseAttribute(token ruleToken) (*router.Domain_Attribute, error) {
+	if token.text[0] != '@' {
+		return nil, tokenError(token, 0, "invalid attribute: %s", token.text)
+	}
+	attr := token.text[1:] // 移除属性前缀 `@` 字符
+	if attr == "" || strings.Contains(attr, "@") {
+		return nil, tokenError(token, 0, "invalid attribute: %s", token.text)
 	}
-	attr = attr[1:] // 移除属性前缀 `@` 字符
 
 	var attribute router.Domain_Attribute
 	attribute.Key = strings.ToLower(attr) // 属性键转小写
@@ -190,13 +396,7 @@ func (l *ListInfo) classifyRule(rule *router.Domain) {
 	// 规则分类逻辑：优先判断是否有属性
 	if len(rule.Attribute) > 0 {
 		// 带有属性的规则
-		l.AttributeRuleUniqueList = append(l.AttributeRuleUniqueList, rule)
-		var attrsString attribute
-		// 构造一个包含所有属性的字符串作为 map 的键，例如 "@cn@ads"
-		for _, attr := range rule.Attribute {
-			attrsString += attribute("@" + attr.GetKey())
-		}
-		l.AttributeRuleListMap[attrsString] = append(l.AttributeRuleListMap[attrsString], rule)
+		l.addAttributeRule(rule)
 	} else {
 		// 不带属性的规则，按类型分类
 		switch rule.Type {
@@ -212,44 +412,46 @@ func (l *ListInfo) classifyRule(rule *router.Domain) {
 	}
 }
 
-// Flatten 展平文件中的 `include` 规则，将所需规则添加到当前 ListInfo 中。
+// addAttributeRule 将带有属性的规则写入 AttributeRuleUniqueList 和 AttributeRuleListMap。
+func (l *ListInfo) addAttributeRule(rule *router.Domain) {
+	l.AttributeRuleUniqueList = append(l.AttributeRuleUniqueList, rule)
+	var attrsString attribute
+	// 构造一个包含所有属性的字符串作为 map 的键，例如 "@cn@ads"
+	for _, attr := range rule.Attribute {
+		attrsString += attribute("@" + attr.GetKey())
+	}
+	l.AttributeRuleListMap[attrsString] = append(l.AttributeRuleListMap[attrsString], rule)
+}
+
+// Flatten 展平文件中的 `include` 规则，将所需规则添加到当前 ListInfo 中，然后应用排除规则。
 // 它还为 domain 类型的规则生成一个域名前缀树（trie）以进行去重。
 func (l *ListInfo) Flatten(lm *ListInfoMap) error {
 	if l.HasInclusion {
-		// 遍历所有包含的列表文件及其属性
-		for filename, attrs := range l.InclusionAttributeMap {
-			for _, attrWanted := range attrs {
-				includedList := (*lm)[filename] // 被包含的 ListInfo
-
-				switch string(attrWanted) {
-				case "@": // 包含所有规则（包括无属性和有属性的）
-					// 直接追加无属性的规则列表
+		// 遍历所有包含的列表文件及其属性过滤条件
+		for filename, filters := range l.InclusionAttributeMap {
+			includedList := (*lm)[filename] // 被包含的 ListInfo
+			for _, filter := range filters {
+				// 不带属性的规则只在没有必需属性时被包含，例如 `include:google` 或 `include:google @!cn`
+				if len(filter.mustAttrs) == 0 {
 					l.FullTypeList = append(l.FullTypeList, includedList.FullTypeList...)
 					l.DomainTypeList = append(l.DomainTypeList, includedList.DomainTypeList...)
 					l.KeywordTypeList = append(l.KeywordTypeList, includedList.KeywordTypeList...)
 					l.RegexpTypeList = append(l.RegexpTypeList, includedList.RegexpTypeList...)
+				}
 
-					// 直接追加所有带属性的规则
-					l.AttributeRuleUniqueList = append(l.AttributeRuleUniqueList, includedList.AttributeRuleUniqueList...)
-					for attr, domainList := range includedList.AttributeRuleListMap {
-						l.AttributeRuleListMap[attr] = append(l.AttributeRuleListMap[attr], domainList...)
-					}
-
-				default: // 包含带有特定属性的规则，例如 "@cn"
-					for attr, domainList := range includedList.AttributeRuleListMap {
-						// 检查被包含规则的属性是否包含 attrWanted。
-						// 为了处理多属性规则（如 "@cn@ads"），使用 "@" 作为分隔符进行判断。
-						if strings.Contains(string(attr)+"@", string(attrWanted)+"@") {
-							// 追加到当前列表的 AttributeRuleListMap 和 AttributeRuleUniqueList 中
-							l.AttributeRuleListMap[attr] = append(l.AttributeRuleListMap[attr], domainList...)
-							l.AttributeRuleUniqueList = append(l.AttributeRuleUniqueList, domainList...)
-						}
+				// 带有属性的规则按过滤条件筛选
+				for _, rule := range includedList.AttributeRuleUniqueList {
+					if filter.match(rule) {
+						l.addAttributeRule(rule)
 					}
 				}
 			}
 		}
 	}
 
+	// 在包含之后应用排除规则，使其可以移除从其他列表包含进来的规则
+	l.applyExclusions()
+
 	// 对 domain 类型的规则进行排序，使得子域（点号更多）排在前面
 	sort.Slice(l.DomainTypeList, func(i, j int) bool {
 		return len(strings.Split(l.DomainTypeList[i].GetValue(), ".")) < len(strings.Split(l.DomainTypeList[j].GetValue(), "."))
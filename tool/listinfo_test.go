@@ -0,0 +1,134 @@
+package tool
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+func TestTokenizeRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want []ruleToken
+	}{
+		{line: "google.com", want: []ruleToken{{"google.com", 1}}},
+		{line: "  full:a.com\t @cn   @ads", want: []ruleToken{{"full:a.com", 3}, {"@cn", 15}, {"@ads", 21}}},
+		{line: "域名.中国 @cn", want: []ruleToken{{"域名.中国", 1}, {"@cn", 7}}},
+		{line: " \t ", want: nil},
+	}
+	for _, test := range tests {
+		if got := tokenizeRule(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestExcludes(t *testing.T) {
+	domain := func(ruleType router.Domain_Type, value string) *router.Domain {
+		return &router.Domain{Type: ruleType, Value: value}
+	}
+	tests := []struct {
+		exclusion, rule *router.Domain
+		want            bool
+	}{
+		{domain(router.Domain_Domain, "a.com"), domain(router.Domain_Domain, "a.com"), true},
+		{domain(router.Domain_Domain, "a.com"), domain(router.Domain_Domain, "b.a.com"), true},
+		{domain(router.Domain_Domain, "a.com"), domain(router.Domain_Full, "www.a.com"), true},
+		{domain(router.Domain_Domain, "a.com"), domain(router.Domain_Domain, "ba.com"), false},
+		{domain(router.Domain_Domain, "b.a.com"), domain(router.Domain_Domain, "a.com"), false},
+		{domain(router.Domain_Domain, "a.com"), domain(router.Domain_Plain, "a.com"), false},
+		{domain(router.Domain_Full, "a.com"), domain(router.Domain_Full, "a.com"), true},
+		{domain(router.Domain_Full, "a.com"), domain(router.Domain_Full, "www.a.com"), false},
+		{domain(router.Domain_Full, "a.com"), domain(router.Domain_Domain, "a.com"), false},
+		{domain(router.Domain_Plain, "goog"), domain(router.Domain_Plain, "goog"), true},
+		{domain(router.Domain_Regex, "^a$"), domain(router.Domain_Regex, "^a$"), true},
+	}
+	for _, test := range tests {
+		if got := excludes(test.exclusion, test.rule); got != test.want {
+			t.Errorf("excludes(%v, %v): got %v, want %v", test.exclusion, test.rule, got, test.want)
+		}
+	}
+}
+
+func TestParseInclusion(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    map[fileName][]*attributeFilter
+		wantErr bool
+	}{
+		{
+			line: "include:google",
+			want: map[fileName][]*attributeFilter{"GOOGLE": {{}}},
+		},
+		{
+			line: "include:google @cn @!ads",
+			want: map[fileName][]*attributeFilter{"GOOGLE": {{mustAttrs: []attribute{"cn"}, banAttrs: []attribute{"ads"}}}},
+		},
+		{
+			line: "include:google@cn@gfw",
+			want: map[fileName][]*attributeFilter{"GOOGLE": {{mustAttrs: []attribute{"cn", "gfw"}}}},
+		},
+		{
+			line: "include:google@!cn @ads",
+			want: map[fileName][]*attributeFilter{"GOOGLE": {{mustAttrs: []attribute{"ads"}, banAttrs: []attribute{"cn"}}}},
+		},
+		{line: "include:", wantErr: true},
+		{line: "include:@cn", wantErr: true},
+		{line: "include:google@", wantErr: true},
+		{line: "include:google cn", wantErr: true},
+	}
+	for _, test := range tests {
+		list := NewListInfo()
+		err := list.parseInclusion(tokenizeRule(test.line))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error %v", test.line, err, test.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(list.InclusionAttributeMap, test.want) {
+			t.Errorf("%q: got %v, want %v", test.line, list.InclusionAttributeMap, test.want)
+		}
+	}
+}
+
+func TestParseInclusionAttachedAttributes(t *testing.T) {
+	tests := []struct {
+		line       string
+		want       map[fileName][]*attributeFilter
+		wantColumn int // 出错时的列号，0 表示不出错
+	}{
+		{
+			line: "include:google@cn",
+			want: map[fileName][]*attributeFilter{"GOOGLE": {{mustAttrs: []attribute{"cn"}}}},
+		},
+		{
+			line: "include:谷歌@属性@cn",
+			want: map[fileName][]*attributeFilter{"谷歌": {{mustAttrs: []attribute{"属性", "cn"}}}},
+		},
+		{line: "include:google@cn@", wantColumn: 18},
+		{line: "include:谷歌@cn@", wantColumn: 14},
+		{line: "include:google@属性@", wantColumn: 18},
+		{line: "  include:谷歌@属性@@cn", wantColumn: 16},
+	}
+	for _, test := range tests {
+		list := NewListInfo()
+		err := list.parseInclusion(tokenizeRule(test.line))
+		if test.wantColumn == 0 {
+			if err != nil {
+				t.Errorf("%q: got error %v", test.line, err)
+			} else if !reflect.DeepEqual(list.InclusionAttributeMap, test.want) {
+				t.Errorf("%q: got %v, want %v", test.line, list.InclusionAttributeMap, test.want)
+			}
+			continue
+		}
+		var ruleErr *ruleError
+		if !errors.As(err, &ruleErr) {
+			t.Errorf("%q: got error %v, want error at column %d", test.line, err, test.wantColumn)
+			continue
+		}
+		if ruleErr.column != test.wantColumn {
+			t.Errorf("%q: got column %d, want %d", test.line, ruleErr.column, test.wantColumn)
+		}
+	}
+}
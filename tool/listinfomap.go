@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	router "github.com/xtls/xray-core/app/router"
@@ -13,7 +14,8 @@ import (
 type ListInfoMap map[fileName]*ListInfo
 
 // Marshal 处理数据目录中的一个文件，为其生成并返回 ListInfo。
-func (lm *ListInfoMap) Marshal(path string) error {
+// 无法解析的规则追加到 ruleErrs 中。
+func (lm *ListInfoMap) Marshal(path string, ruleErrs *[]*ruleError) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
@@ -24,9 +26,10 @@ func (lm *ListInfoMap) Marshal(path string) error {
 	// 文件名（去除路径，转大写）作为 ListInfo 的名称
 	listName := fileName(strings.ToUpper(filepath.Base(path)))
 	list.Name = listName
+	list.Path = path
 
 	// 处理文件内容，填充 ListInfo
-	if err := list.ProcessList(file); err != nil {
+	if err := list.ProcessList(file, ruleErrs); err != nil {
 		return err
 	}
 
@@ -36,69 +39,73 @@ func (lm *ListInfoMap) Marshal(path string) error {
 
 // FlattenAndGenUniqueDomainList 展平包含（include）的列表，并为每个文件的 domain 类型规则生成
 // 唯一的（去重后的）域名列表。
+//
+// 列表按依赖关系进行拓扑排序，被包含的列表总是先于包含它的列表展平。
+// 存在循环包含时返回包含完整循环路径的错误；包含了不存在的列表时返回该列表名及引用它的文件。
 func (lm *ListInfoMap) FlattenAndGenUniqueDomainList() error {
-	// Inclusion Level 算法用于按依赖顺序（level）处理文件，确保被包含的列表先于包含它的列表被展平。
-	inclusionLevel := make([]map[fileName]bool, 0, 20) // 存储每个级别的文件名
-	okayList := make(map[fileName]bool)                // 存储已处理/可以处理的文件名
-	inclusionLevelAllLength, loopTimes := 0, 0         // 已处理文件的总数和循环次数
-
-	// 循环直到所有文件都被分级
-	for inclusionLevelAllLength < len(*lm) {
-		inclusionMap := make(map[fileName]bool)
-
-		if loopTimes == 0 {
-			// Level 1: 处理所有不包含任何其他文件的列表
-			for _, listinfo := range *lm {
-				if listinfo.HasInclusion {
-					continue
-				}
-				inclusionMap[listinfo.Name] = true
+	walker := newDependencyWalker[fileName]("include")
+	levels := make(map[fileName]int) // 依赖级别：不包含其他列表的为 1，否则为所包含列表的最大级别 + 1
+	var order []fileName             // 展平顺序
+
+	var visit func(name fileName) error
+	visit = func(name fileName) error {
+		if done, err := walker.enter(name); done || err != nil {
+			return err
+		}
+
+		listinfo := (*lm)[name]
+		level := 1
+		// 按名称排序，保证错误信息稳定
+		included := make([]fileName, 0, len(listinfo.InclusionAttributeMap))
+		for filename := range listinfo.InclusionAttributeMap {
+			included = append(included, filename)
+		}
+		sort.Slice(included, func(i, j int) bool { return included[i] < included[j] })
+		for _, filename := range included {
+			if (*lm)[filename] == nil {
+				return fmt.Errorf("%s: include:%s refers to a missing list", listinfo.Path, strings.ToLower(string(filename)))
 			}
-		} else {
-			// Level 2+ : 处理所有依赖（包含）的文件都已在 okayList 中的列表
-			for _, listinfo := range *lm {
-				// 跳过没有包含规则或已经处理过的文件
-				if !listinfo.HasInclusion || okayList[listinfo.Name] {
-					continue
-				}
-
-				var passTimes int
-				// 检查所有它包含的文件是否都已处理
-				for filename := range listinfo.InclusionAttributeMap {
-					if !okayList[filename] {
-						break // 依赖的文件未处理，跳出
-					}
-					passTimes++
-				}
-
-				// 如果所有依赖的文件都已处理，则当前文件可以被处理
-				if passTimes == len(listinfo.InclusionAttributeMap) {
-					inclusionMap[listinfo.Name] = true
-				}
+			if err := visit(filename); err != nil {
+				return err
 			}
+			level = max(level, levels[filename]+1)
 		}
 
-		// 将当前级别的所有文件添加到 okayList
-		for filename := range inclusionMap {
-			okayList[filename] = true
-		}
+		walker.leave()
+		levels[name] = level
+		order = append(order, name)
+		return nil
+	}
 
-		inclusionLevel = append(inclusionLevel, inclusionMap)
-		inclusionLevelAllLength += len(inclusionMap)
-		loopTimes++
+	names := make([]fileName, 0, len(*lm))
+	for name := range *lm {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
 	}
 
-	// 按照确定的依赖级别顺序进行展平（Flatten）操作
+	// 按级别打印列表
+	inclusionLevel := make([]map[fileName]bool, 0, 20)
+	for _, name := range order {
+		for len(inclusionLevel) < levels[name] {
+			inclusionLevel = append(inclusionLevel, make(map[fileName]bool))
+		}
+		inclusionLevel[levels[name]-1][name] = true
+	}
 	for idx, inclusionMap := range inclusionLevel {
 		fmt.Printf("Level %d:\n", idx+1)
 		fmt.Println(inclusionMap)
 		fmt.Println()
+	}
 
-		for inclusionFilename := range inclusionMap {
-			// 对当前级别的每个文件执行展平操作
-			if err := (*lm)[inclusionFilename].Flatten(lm); err != nil {
-				return err
-			}
+	// 按拓扑顺序进行展平（Flatten）操作
+	for _, name := range order {
+		if err := (*lm)[name].Flatten(lm); err != nil {
+			return err
 		}
 	}
 
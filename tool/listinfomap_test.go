@@ -0,0 +1,108 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFlattenAndGenUniqueDomainList(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string][]string // 列表名 -> 域名（排序后）
+		wantErr string
+	}{
+		{
+			// 按名称顺序处理时 a 会先于 b、c 展平，拓扑排序保证被包含的列表先展平
+			name: "include chain",
+			files: map[string]string{
+				"a": "a.com\ninclude:b\n",
+				"b": "b.com\ninclude:c\n",
+				"c": "c.com\nfull:www.c.com @cn\n",
+			},
+			want: map[string][]string{
+				"A": {"a.com", "b.com", "c.com"},
+				"B": {"b.com", "c.com"},
+				"C": {"c.com"},
+			},
+		},
+		{
+			name: "diamond",
+			files: map[string]string{
+				"top":   "include:left\ninclude:right\n",
+				"left":  "l.com\ninclude:base\n",
+				"right": "r.com\ninclude:base @cn\n",
+				"base":  "base.com\nfull:www.cn.com @cn\n",
+			},
+			want: map[string][]string{
+				"TOP": {"base.com", "l.com", "r.com"},
+			},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"x": "include:y\n",
+				"y": "include:z\n",
+				"z": "include:x\n",
+			},
+			wantErr: "include cycle detected: X -> Y -> Z -> X",
+		},
+		{
+			name:    "missing list",
+			files:   map[string]string{"x": "include:nope\n"},
+			wantErr: "include:nope refers to a missing list",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			lm := make(ListInfoMap)
+			var ruleErrs []*ruleError
+			for name, content := range test.files {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := lm.Marshal(path, &ruleErrs); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(ruleErrs) > 0 {
+				t.Fatalf("unexpected rule errors: %v", ruleErrs)
+			}
+
+			err := lm.FlattenAndGenUniqueDomainList()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string][]string)
+			for _, site := range lm.ToProto(nil).GetEntry() {
+				var domains []string
+				for _, domain := range site.GetDomain() {
+					if len(domain.GetAttribute()) == 0 {
+						domains = append(domains, domain.GetValue())
+					}
+				}
+				sort.Strings(domains)
+				got[site.GetCountryCode()] = domains
+			}
+			for name, want := range test.want {
+				if !reflect.DeepEqual(got[name], want) {
+					t.Errorf("%s: got %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package tool
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// GeoLite2 Country CSV 数据包中的文件名
+const (
+	maxmindBlocksIPv4File   = "GeoLite2-Country-Blocks-IPv4.csv"
+	maxmindBlocksIPv6File   = "GeoLite2-Country-Blocks-IPv6.csv"
+	maxmindLocationsFile    = "GeoLite2-Country-Locations-en.csv"
+	maxmindLocationsPattern = "GeoLite2-Country-Locations-*.csv"
+)
+
+// wantedCodeSet 解析 -wanted 参数，返回需要保留的国家/地区代码集合（大写）。
+// 返回 nil 表示保留所有代码。
+func wantedCodeSet() map[string]bool {
+	if strings.TrimSpace(*wantedCodes) == "" {
+		return nil
+	}
+	wanted := make(map[string]bool)
+	for _, code := range strings.Split(*wantedCodes, ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			wanted[code] = true
+		}
+	}
+	return wanted
+}
+
+// openCSV 打开一个带表头的 CSV 文件，返回 reader 以及列名到列序号的映射。
+func openCSV(path string) (*os.File, *csv.Reader, map[string]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for idx, name := range header {
+		// 去除可能存在的 UTF-8 BOM
+		columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = idx
+	}
+	return file, reader, columns, nil
+}
+
+// readMaxMindLocations 读取 Locations CSV，返回 geoname_id 到 ISO 国家代码（大写）的映射。
+// 只有大洲代码的条目（如欧洲、亚洲整体分配）不会加入映射：大洲代码与部分国家代码相同
+// （如 AS 美属萨摩亚、NA 纳米比亚、SA 沙特阿拉伯、AF 阿富汗），不能作为国家代码使用。
+func readMaxMindLocations(path string) (map[string]string, error) {
+	file, reader, columns, err := openCSV(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	idCol, ok1 := columns["geoname_id"]
+	isoCol, ok2 := columns["country_iso_code"]
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s: missing geoname_id or country_iso_code column", path)
+	}
+
+	locations := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if code := strings.ToUpper(strings.TrimSpace(record[isoCol])); code != "" {
+			locations[strings.TrimSpace(record[idCol])] = code
+		}
+	}
+	return locations, nil
+}
+
+// readMaxMindBlocks 读取 Blocks CSV，将每个网段按国家代码追加到 dataDirMap 中。
+// 优先使用 geoname_id，若为空或只对应大洲则使用 registered_country_geoname_id。
+func readMaxMindBlocks(path string, locations map[string]string, wanted map[string]bool, dataDirMap map[string][]*router.CIDR) error {
+	file, reader, columns, err := openCSV(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	networkCol, ok1 := columns["network"]
+	idCol, ok2 := columns["geoname_id"]
+	registeredCol, ok3 := columns["registered_country_geoname_id"]
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("%s: missing network, geoname_id or registered_country_geoname_id column", path)
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		code, found := locations[strings.TrimSpace(record[idCol])]
+		if !found {
+			code, found = locations[strings.TrimSpace(record[registeredCol])]
+		}
+		if !found {
+			continue // 没有国家信息的网段（如匿名代理）
+		}
+		if wanted != nil && !wanted[code] {
+			continue
+		}
+
+		cidr, err := ParseIP(strings.TrimSpace(record[networkCol]))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		dataDirMap[code] = append(dataDirMap[code], cidr)
+	}
+	return nil
+}
+
+// getCidrFromMaxMindCSV 从 GeoLite2 Country CSV 目录中读取 IPv4/IPv6 网段，
+// 并按 ISO 国家代码分组追加到 dataDirMap 中。
+func getCidrFromMaxMindCSV(dir string, dataDirMap map[string][]*router.CIDR) error {
+	locationsPath := filepath.Join(dir, maxmindLocationsFile)
+	if _, err := os.Stat(locationsPath); err != nil {
+		// 没有英文版时，使用任意语言版本的 Locations 文件（国家代码与语言无关）
+		matches, _ := filepath.Glob(filepath.Join(dir, maxmindLocationsPattern))
+		if len(matches) == 0 {
+			return errors.New("no GeoLite2 Country locations file found in " + dir)
+		}
+		locationsPath = matches[0]
+	}
+
+	locations, err := readMaxMindLocations(locationsPath)
+	if err != nil {
+		return err
+	}
+
+	wanted := wantedCodeSet()
+	for _, blocksFile := range []string{maxmindBlocksIPv4File, maxmindBlocksIPv6File} {
+		blocksPath := filepath.Join(dir, blocksFile)
+		if _, err := os.Stat(blocksPath); os.IsNotExist(err) {
+			fmt.Printf("%s not found, skipped.\n", blocksPath)
+			continue
+		}
+		if err := readMaxMindBlocks(blocksPath, locations, wanted, dataDirMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+const testMaxMindLocations = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+6255147,en,AS,Asia,,,0
+6255148,en,EU,Europe,,,1
+6255149,en,NA,"North America",,,0
+1814991,en,AS,Asia,CN,China,0
+5880801,en,OC,Oceania,AS,"American Samoa",0
+3355338,en,AF,Africa,NA,Namibia,0
+102358,en,AS,Asia,SA,"Saudi Arabia",0
+`
+
+func TestReadMaxMindContinentCollision(t *testing.T) {
+	dir := t.TempDir()
+	locationsPath := filepath.Join(dir, maxmindLocationsFile)
+	if err := os.WriteFile(locationsPath, []byte(testMaxMindLocations), 0644); err != nil {
+		t.Fatal(err)
+	}
+	locations, err := readMaxMindLocations(locationsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		blocks string
+		want   map[string][]string
+	}{
+		{
+			name: "country",
+			blocks: "network,geoname_id,registered_country_geoname_id\n" +
+				"1.0.1.0/24,1814991,1814991\n" +
+				"1.0.2.0/24,5880801,5880801\n",
+			want: map[string][]string{"CN": {"1.0.1.0/24"}, "AS": {"1.0.2.0/24"}},
+		},
+		{
+			name: "continent only falls back to registered country",
+			blocks: "network,geoname_id,registered_country_geoname_id\n" +
+				"2.0.0.0/24,6255147,102358\n" +
+				"2.0.1.0/24,6255149,3355338\n",
+			want: map[string][]string{"SA": {"2.0.0.0/24"}, "NA": {"2.0.1.0/24"}},
+		},
+		{
+			name: "continent only without country is dropped",
+			blocks: "network,geoname_id,registered_country_geoname_id\n" +
+				"3.0.0.0/24,6255147,\n" +
+				"3.0.1.0/24,6255148,6255148\n",
+			want: map[string][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocksPath := filepath.Join(t.TempDir(), maxmindBlocksIPv4File)
+			if err := os.WriteFile(blocksPath, []byte(test.blocks), 0644); err != nil {
+				t.Fatal(err)
+			}
+			dataDirMap := make(map[string][]*router.CIDR)
+			if err := readMaxMindBlocks(blocksPath, locations, nil, dataDirMap); err != nil {
+				t.Fatal(err)
+			}
+			if got := cidrStrings(t, dataDirMap); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
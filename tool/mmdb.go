@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// mmdbCountryRecord 是 MMDB 数据库中国家信息记录的通用结构。
+// 兼容以下格式：
+//   - GeoLite2-Country / DB-IP lite：country.iso_code、registered_country.iso_code
+//   - ipinfo country：country 字段直接是 ISO 代码，或使用 country_code 字段
+type mmdbCountryRecord struct {
+	Country           any    `maxminddb:"country"`
+	RegisteredCountry any    `maxminddb:"registered_country"`
+	CountryCode       string `maxminddb:"country_code"`
+}
+
+// isoCodeOf 从 country 字段中提取 ISO 代码，该字段可能是字符串或包含 iso_code 的 map。
+func isoCodeOf(field any) string {
+	switch v := field.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if code, ok := v["iso_code"].(string); ok {
+			return code
+		}
+	}
+	return ""
+}
+
+// isoCode 返回记录对应的 ISO 国家代码（大写），优先使用 country，其次为 registered_country。
+func (r *mmdbCountryRecord) isoCode() string {
+	code := isoCodeOf(r.Country)
+	if code == "" {
+		code = isoCodeOf(r.RegisteredCountry)
+	}
+	if code == "" {
+		code = r.CountryCode
+	}
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// getCidrFromMMDB 读取 MMDB 数据库中的所有网段，并按 ISO 国家代码分组追加到 dataDirMap 中。
+// IPv4 网段只读取一次（跳过 ::ffff:0:0/96 等 IPv4 别名子树）。
+func getCidrFromMMDB(path string, dataDirMap map[string][]*router.CIDR) error {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Printf("Reading %s (%s).\n", path, db.Metadata.DatabaseType)
+
+	wanted := wantedCodeSet()
+	networks := db.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record mmdbCountryRecord
+		network, err := networks.Network(&record)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		code := record.isoCode()
+		if code == "" {
+			continue // 没有国家信息的网段
+		}
+		if wanted != nil && !wanted[code] {
+			continue
+		}
+
+		addr, ok := netip.AddrFromSlice(network.IP)
+		if !ok {
+			return fmt.Errorf("%s: invalid network %s", path, network)
+		}
+		bits, _ := network.Mask.Size()
+		dataDirMap[code] = append(dataDirMap[code], prefixToCIDR(netip.PrefixFrom(addr, bits)))
+	}
+	if err := networks.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package tool
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// mmdbAliasedPrefixes 是 MMDB IPv6 树中映射到 IPv4 子树的别名网段，无法直接写入数据。
+var mmdbAliasedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// isMMDBAliased 检查网段是否位于 MMDB 的 IPv4 别名网段内。
+func isMMDBAliased(prefix netip.Prefix) bool {
+	for _, aliased := range mmdbAliasedPrefixes {
+		if aliased.Bits() <= prefix.Bits() && aliased.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// mmdbCountryCodes 从 dataDirMap 中选出写入 MMDB 的代码：来自 data 目录和国家/地区级数据源的代码（见 sources）。
+// 分组、集合、ASN、云服务商和特殊用途代码，以及包含了其他代码（include 指令）或输出为反向匹配的代码
+// 都不写入 MMDB：它们与国家/地区代码重叠，按字母顺序写入时会覆盖国家/地区代码
+// （例如 CN 的地址被写为 CONTINENT-AS）。
+// 来自数据源但因 include 或 reverse 指令而跳过的代码会打印出来，以免 MMDB 与 geoip.dat 的差异被忽略。
+func mmdbCountryCodes(dataDirMap map[string][]*router.CIDR, sources map[string][]string, directives map[string]*geoipDirective, reverseCodes map[string]bool) map[string][]*router.CIDR {
+	mmdbList := make(map[string][]*router.CIDR, len(sources))
+	var included, reversed []string
+	for code := range sources {
+		if reverseCodes[code] {
+			reversed = append(reversed, code)
+			continue
+		}
+		if directive := directives[code]; directive != nil && len(directive.includes) > 0 {
+			included = append(included, code)
+			continue
+		}
+		if cidrs, found := dataDirMap[code]; found {
+			mmdbList[code] = cidrs
+		}
+	}
+
+	sort.Strings(included)
+	sort.Strings(reversed)
+	if len(included) > 0 {
+		fmt.Printf("Skipped in MMDB (include other codes): %s\n", strings.Join(included, ", "))
+	}
+	if len(reversed) > 0 {
+		fmt.Printf("Skipped in MMDB (reverse match): %s\n", strings.Join(reversed, ", "))
+	}
+	return mmdbList
+}
+
+// writeMMDB 将 dataDirMap 中的所有 CIDR 写入与 GeoLite2-Country 结构相同的 MMDB 文件，
+// 每个网段的记录为 {"country": {"iso_code": <代码>}}。
+// 代码按字母顺序写入，若不同代码的网段重叠，后写入的代码覆盖先写入的代码。
+func writeMMDB(dataDirMap map[string][]*router.CIDR, path string) error {
+	writer, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "GeoLite2-Country",
+		Description:             map[string]string{"en": "GeoLite2-Country compatible database generated by geofile"},
+		Languages:               []string{"en"},
+		IncludeReservedNetworks: true, // data 目录中的代码可能包含私有或保留网段，允许写入这些网段
+		RecordSize:              24,
+	})
+	if err != nil {
+		return err
+	}
+
+	codes := make([]string, 0, len(dataDirMap))
+	for cc := range dataDirMap {
+		codes = append(codes, cc)
+	}
+	sort.Strings(codes)
+
+	for _, cc := range codes {
+		record := mmdbtype.Map{
+			"country": mmdbtype.Map{
+				"iso_code": mmdbtype.String(cc),
+			},
+		}
+		for _, cidr := range dataDirMap[cc] {
+			prefix, err := cidrToPrefix(cidr)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cc, err)
+			}
+			if isMMDBAliased(prefix) {
+				fmt.Printf("%s: %s is in an MMDB aliased network, skipped.\n", cc, prefix)
+				continue
+			}
+			network := &net.IPNet{
+				IP:   prefix.Addr().AsSlice(),
+				Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+			}
+			if err := writer.Insert(network, record); err != nil {
+				return fmt.Errorf("%s: %w", cc, err)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := writer.WriteTo(file); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package tool
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	router "github.com/xtls/xray-core/app/router"
+)
+
+func TestWriteMMDBCountryCodes(t *testing.T) {
+	cidrList := map[string][]*router.CIDR{
+		"CN":           mustCIDRs(t, "1.0.1.0/24", "2001:250::/32"),
+		"US":           mustCIDRs(t, "8.8.8.0/24"),
+		"NOT-CN":       mustCIDRs(t, "9.9.9.0/24"),
+		"GREATER-CN":   mustCIDRs(t, "1.0.1.0/24"),
+		"CONTINENT-AS": mustCIDRs(t, "1.0.1.0/24", "2001:250::/32"),
+		"EU":           mustCIDRs(t, "8.8.8.0/24"),
+		"AS4134":       mustCIDRs(t, "1.0.1.0/24"),
+		"PRIVATE":      mustCIDRs(t, "127.0.0.0/8"),
+		"RESERVED":     mustCIDRs(t, "127.0.0.0/8"),
+	}
+	sources := map[string][]string{
+		"CN":         {"data/cn.txt", "GeoLite2-Country-CSV"},
+		"US":         {"GeoLite2-Country-CSV"},
+		"NOT-CN":     {"data/not-cn.txt"},
+		"GREATER-CN": {"data/greater-cn.txt"},
+	}
+	directives := map[string]*geoipDirective{
+		"NOT-CN":     {path: "data/not-cn.txt", reverse: true},
+		"GREATER-CN": {path: "data/greater-cn.txt", includes: []string{"CN"}},
+	}
+	reverseCodes := map[string]bool{"NOT-CN": true}
+
+	path := filepath.Join(t.TempDir(), "Country.mmdb")
+	if err := writeMMDB(mmdbCountryCodes(cidrList, sources, directives, reverseCodes), path); err != nil {
+		t.Fatal(err)
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		ip   string
+		want string // 空字符串表示没有记录
+	}{
+		{"1.0.1.1", "CN"},
+		{"2001:250::1", "CN"},
+		{"8.8.8.8", "US"},
+		{"9.9.9.9", ""},
+		{"127.0.0.1", ""},
+	}
+	for _, test := range tests {
+		var record mmdbCountryRecord
+		if err := db.Lookup(net.ParseIP(test.ip), &record); err != nil {
+			t.Fatal(err)
+		}
+		if got := record.isoCode(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.ip, got, test.want)
+		}
+	}
+}
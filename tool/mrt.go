@@ -0,0 +1,253 @@
+package tool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// MRT 格式常量，参见 RFC 6396 和 RFC 8050。
+const (
+	mrtTypeTableDumpV2 = 13
+
+	mrtSubtypePeerIndexTable          = 1
+	mrtSubtypeRIBIPv4Unicast          = 2
+	mrtSubtypeRIBIPv4Multicast        = 3
+	mrtSubtypeRIBIPv6Unicast          = 4
+	mrtSubtypeRIBIPv6Multicast        = 5
+	mrtSubtypeRIBIPv4UnicastAddPath   = 8
+	mrtSubtypeRIBIPv4MulticastAddPath = 9
+	mrtSubtypeRIBIPv6UnicastAddPath   = 10
+	mrtSubtypeRIBIPv6MulticastAddPath = 11
+
+	bgpAttrFlagExtendedLength = 0x10
+	bgpAttrTypeASPath         = 2
+
+	bgpASPathSegmentSet      = 1
+	bgpASPathSegmentSequence = 2
+)
+
+// errMRTTruncated 表示 MRT 记录的长度与其内容不符。
+var errMRTTruncated = errors.New("truncated MRT record")
+
+// getCidrFromMRT 读取 MRT TABLE_DUMP_V2 格式的 RIB 快照（如 RouteViews rib.*.bz2、RIPE RIS bview.*.gz，
+// paths 为逗号分隔的文件列表），根据每个前缀的起源 ASN 为配置中的 ASN 和 ASN 分组生成 geoip 代码。
+//
+// 起源 ASN 的判定规则：
+//   - 取 AS_PATH 中最后一个 AS_SEQUENCE 或 AS_SET 段（忽略联盟段）
+//   - 最后一段为 AS_SEQUENCE 时，起源为其最后一个 ASN
+//   - 最后一段为 AS_SET 时，集合中的每个 ASN 都视为起源
+//   - 不同对等体观察到不同起源（MOAS）时，前缀归属于所有观察到的起源
+func getCidrFromMRT(paths string, config *Config, dataDirMap map[string][]*router.CIDR) error {
+	targets := asnTargets(config)
+	if len(targets) == 0 {
+		fmt.Println("No ASN configured in [geoip] section, skipped MRT dump.")
+		return nil
+	}
+
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := readMRTFile(path, targets, dataDirMap); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// readMRTFile 逐条读取 MRT 记录，只处理 TABLE_DUMP_V2 的单播和组播 RIB 记录。
+func readMRTFile(path string, targets map[uint32][]string, dataDirMap map[string][]*router.CIDR) error {
+	file, err := openCompressed(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+	header := make([]byte, 12)
+	var body []byte
+	for {
+		// MRT 通用头部：timestamp(4) type(2) subtype(2) length(4)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		recordType := binary.BigEndian.Uint16(header[4:6])
+		subtype := binary.BigEndian.Uint16(header[6:8])
+		length := binary.BigEndian.Uint32(header[8:12])
+
+		if cap(body) < int(length) {
+			body = make([]byte, length)
+		}
+		body = body[:length]
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return err
+		}
+
+		if recordType != mrtTypeTableDumpV2 {
+			continue
+		}
+
+		var ipv6, addPath bool
+		switch subtype {
+		case mrtSubtypeRIBIPv4Unicast, mrtSubtypeRIBIPv4Multicast:
+		case mrtSubtypeRIBIPv6Unicast, mrtSubtypeRIBIPv6Multicast:
+			ipv6 = true
+		case mrtSubtypeRIBIPv4UnicastAddPath, mrtSubtypeRIBIPv4MulticastAddPath:
+			addPath = true
+		case mrtSubtypeRIBIPv6UnicastAddPath, mrtSubtypeRIBIPv6MulticastAddPath:
+			ipv6, addPath = true, true
+		default:
+			continue // PEER_INDEX_TABLE、RIB_GENERIC 等记录
+		}
+
+		prefix, origins, err := parseMRTRIB(body, ipv6, addPath)
+		if err != nil {
+			return err
+		}
+		if len(origins) > 0 {
+			addASNCIDRs(origins, []*router.CIDR{prefixToCIDR(prefix)}, targets, dataDirMap)
+		}
+	}
+}
+
+// parseMRTRIB 解析一条 RIB 记录，返回前缀及其所有（去重后的）起源 ASN。记录格式：
+//
+//	sequence(4) prefix_length(1) prefix(变长) entry_count(2)
+//	entries: peer_index(2) originated_time(4) [path_id(4)] attribute_length(2) attributes
+func parseMRTRIB(body []byte, ipv6, addPath bool) (netip.Prefix, []uint32, error) {
+	if len(body) < 5 {
+		return netip.Prefix{}, nil, errMRTTruncated
+	}
+	bits := int(body[4])
+	prefixBytes := (bits + 7) / 8
+	offset := 5 + prefixBytes
+	if len(body) < offset+2 {
+		return netip.Prefix{}, nil, errMRTTruncated
+	}
+
+	var addr netip.Addr
+	if ipv6 {
+		var b [16]byte
+		if prefixBytes > 16 {
+			return netip.Prefix{}, nil, fmt.Errorf("invalid IPv6 prefix length: %d", bits)
+		}
+		copy(b[:], body[5:offset])
+		addr = netip.AddrFrom16(b)
+	} else {
+		var b [4]byte
+		if prefixBytes > 4 {
+			return netip.Prefix{}, nil, fmt.Errorf("invalid IPv4 prefix length: %d", bits)
+		}
+		copy(b[:], body[5:offset])
+		addr = netip.AddrFrom4(b)
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	entryCount := int(binary.BigEndian.Uint16(body[offset : offset+2]))
+	offset += 2
+
+	seen := make(map[uint32]bool)
+	var origins []uint32
+	for i := 0; i < entryCount; i++ {
+		offset += 6 // peer_index(2) originated_time(4)
+		if addPath {
+			offset += 4 // path_id(4)
+		}
+		if len(body) < offset+2 {
+			return netip.Prefix{}, nil, errMRTTruncated
+		}
+		attrLength := int(binary.BigEndian.Uint16(body[offset : offset+2]))
+		offset += 2
+		if len(body) < offset+attrLength {
+			return netip.Prefix{}, nil, errMRTTruncated
+		}
+
+		entryOrigins, err := bgpOriginASNs(body[offset : offset+attrLength])
+		if err != nil {
+			return netip.Prefix{}, nil, err
+		}
+		for _, asn := range entryOrigins {
+			if !seen[asn] {
+				seen[asn] = true
+				origins = append(origins, asn)
+			}
+		}
+		offset += attrLength
+	}
+	return prefix, origins, nil
+}
+
+// bgpOriginASNs 从 BGP 路径属性中找到 AS_PATH，并按 getCidrFromMRT 中描述的规则返回起源 ASN。
+// TABLE_DUMP_V2 中的 AS_PATH 总是使用 4 字节 ASN。
+func bgpOriginASNs(attrs []byte) ([]uint32, error) {
+	for offset := 0; offset < len(attrs); {
+		// 属性头部：flags(1) type(1) length(1 或 2)
+		if len(attrs) < offset+3 {
+			return nil, errMRTTruncated
+		}
+		flags, attrType := attrs[offset], attrs[offset+1]
+		var length int
+		if flags&bgpAttrFlagExtendedLength != 0 {
+			if len(attrs) < offset+4 {
+				return nil, errMRTTruncated
+			}
+			length = int(binary.BigEndian.Uint16(attrs[offset+2 : offset+4]))
+			offset += 4
+		} else {
+			length = int(attrs[offset+2])
+			offset += 3
+		}
+		if len(attrs) < offset+length {
+			return nil, errMRTTruncated
+		}
+
+		if attrType == bgpAttrTypeASPath {
+			return asPathOrigins(attrs[offset : offset+length])
+		}
+		offset += length
+	}
+	return nil, nil
+}
+
+// asPathOrigins 解析 AS_PATH 属性值，返回最后一个非联盟段对应的起源 ASN。
+// 段格式：segment_type(1) segment_length(1) ASN(4 * segment_length)
+func asPathOrigins(path []byte) ([]uint32, error) {
+	var origins []uint32
+	for offset := 0; offset < len(path); {
+		if len(path) < offset+2 {
+			return nil, errMRTTruncated
+		}
+		segmentType, count := path[offset], int(path[offset+1])
+		offset += 2
+		if len(path) < offset+4*count {
+			return nil, errMRTTruncated
+		}
+
+		switch segmentType {
+		case bgpASPathSegmentSequence:
+			if count > 0 {
+				origins = []uint32{binary.BigEndian.Uint32(path[offset+4*(count-1):])}
+			}
+		case bgpASPathSegmentSet:
+			origins = make([]uint32, 0, count)
+			for i := 0; i < count; i++ {
+				origins = append(origins, binary.BigEndian.Uint32(path[offset+4*i:]))
+			}
+		}
+		offset += 4 * count
+	}
+	return origins, nil
+}
@@ -0,0 +1,117 @@
+package tool
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// mrtASPath 构造一个 AS_PATH 属性，每个段为 segment_type 和 ASN 列表。
+func mrtASPath(segments ...[]uint32) []byte {
+	var value []byte
+	for _, segment := range segments {
+		value = append(value, byte(segment[0]), byte(len(segment)-1))
+		for _, asn := range segment[1:] {
+			value = binary.BigEndian.AppendUint32(value, asn)
+		}
+	}
+	return append([]byte{0x40, bgpAttrTypeASPath, byte(len(value))}, value...)
+}
+
+// mrtRIB 构造一条 RIB 记录，每个条目的属性为 attrs 中的一项。
+func mrtRIB(prefix netip.Prefix, addPath bool, attrs ...[]byte) []byte {
+	body := []byte{0, 0, 0, 1, byte(prefix.Bits())}
+	body = append(body, prefix.Addr().AsSlice()[:(prefix.Bits()+7)/8]...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(attrs)))
+	for _, attr := range attrs {
+		body = append(body, 0, 0, 0, 0, 0, 0) // peer_index、originated_time
+		if addPath {
+			body = append(body, 0, 0, 0, 1)
+		}
+		body = binary.BigEndian.AppendUint16(body, uint16(len(attr)))
+		body = append(body, attr...)
+	}
+	return body
+}
+
+func TestParseMRTRIB(t *testing.T) {
+	seq := uint32(bgpASPathSegmentSequence)
+	set := uint32(bgpASPathSegmentSet)
+	v4 := netip.MustParsePrefix("1.0.0.0/24")
+	v6 := netip.MustParsePrefix("2400:cb00::/32")
+
+	tests := []struct {
+		name        string
+		body        []byte
+		ipv6        bool
+		addPath     bool
+		wantPrefix  netip.Prefix
+		wantOrigins []uint32
+		wantErr     error
+	}{
+		{
+			name:        "ipv4 sequence",
+			body:        mrtRIB(v4, false, mrtASPath([]uint32{seq, 3356, 13335})),
+			wantPrefix:  v4,
+			wantOrigins: []uint32{13335},
+		},
+		{
+			name:        "ipv6 add-path",
+			body:        mrtRIB(v6, true, mrtASPath([]uint32{seq, 174, 13335})),
+			ipv6:        true,
+			addPath:     true,
+			wantPrefix:  v6,
+			wantOrigins: []uint32{13335},
+		},
+		{
+			name:        "as set origin",
+			body:        mrtRIB(v4, false, mrtASPath([]uint32{seq, 3356}, []uint32{set, 64500, 64501})),
+			wantPrefix:  v4,
+			wantOrigins: []uint32{64500, 64501},
+		},
+		{
+			name: "origins deduplicated across entries",
+			body: mrtRIB(v4, false,
+				mrtASPath([]uint32{seq, 3356, 13335}),
+				mrtASPath([]uint32{seq, 174, 13335}),
+				mrtASPath([]uint32{seq, 6939, 209242})),
+			wantPrefix:  v4,
+			wantOrigins: []uint32{13335, 209242},
+		},
+		{
+			name:       "no as path",
+			body:       mrtRIB(v4, false, []byte{0x40, 1, 1, 0}),
+			wantPrefix: v4,
+		},
+		{
+			name:    "truncated header",
+			body:    []byte{0, 0, 0, 1},
+			wantErr: errMRTTruncated,
+		},
+		{
+			name:    "truncated attributes",
+			body:    mrtRIB(v4, false, mrtASPath([]uint32{seq, 13335}))[:20],
+			wantErr: errMRTTruncated,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prefix, origins, err := parseMRTRIB(test.body, test.ipv6, test.addPath)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if prefix != test.wantPrefix {
+				t.Errorf("got prefix %s, want %s", prefix, test.wantPrefix)
+			}
+			if !reflect.DeepEqual(origins, test.wantOrigins) {
+				t.Errorf("got origins %v, want %v", origins, test.wantOrigins)
+			}
+		})
+	}
+}
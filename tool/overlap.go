@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+	"go4.org/netipx"
+)
+
+// codeSizes 返回每个代码当前的 CIDR 数量，用于 recordSources 判断数据源写入了哪些代码。
+func codeSizes(dataDirMap map[string][]*router.CIDR) map[string]int {
+	sizes := make(map[string]int, len(dataDirMap))
+	for code, cidrs := range dataDirMap {
+		sizes[code] = len(cidrs)
+	}
+	return sizes
+}
+
+// recordSources 将自上次记录以来 CIDR 数量增加的代码记为来自 source，并更新 sizes。
+func recordSources(dataDirMap map[string][]*router.CIDR, sizes map[string]int, source string, sources map[string][]string) {
+	for code, cidrs := range dataDirMap {
+		if len(cidrs) > sizes[code] {
+			sources[code] = append(sources[code], source)
+		}
+		sizes[code] = len(cidrs)
+	}
+}
+
+// geoipOverlap 是被多个代码同时包含的一段地址范围。
+type geoipOverlap struct {
+	r     netipx.IPRange
+	codes []string // 包含该范围的代码（排序后）
+}
+
+// findOverlaps 找出被两个或更多代码同时包含的地址范围，相邻且代码相同的范围会合并。
+func findOverlaps(sets map[string]*netipx.IPSet) []*geoipOverlap {
+	type event struct {
+		addr netip.Addr
+		code string
+		open bool
+	}
+
+	var overlaps []*geoipOverlap
+	for _, familyMax := range []netip.Addr{
+		netip.MustParseAddr("255.255.255.255"),
+		netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	} {
+		// 每个范围在起始地址处打开，在结束地址的下一个地址处关闭
+		var events []event
+		for code, set := range sets {
+			for _, r := range set.Ranges() {
+				if r.From().Is4() != familyMax.Is4() {
+					continue
+				}
+				events = append(events, event{addr: r.From(), code: code, open: true})
+				if next := r.To().Next(); next.IsValid() {
+					events = append(events, event{addr: next, code: code})
+				}
+			}
+		}
+		sort.Slice(events, func(i, j int) bool {
+			return events[i].addr.Less(events[j].addr)
+		})
+
+		active := make(map[string]bool)
+		var last *geoipOverlap
+		for i := 0; i < len(events); {
+			from := events[i].addr
+			for ; i < len(events) && events[i].addr == from; i++ {
+				if events[i].open {
+					active[events[i].code] = true
+				} else {
+					delete(active, events[i].code)
+				}
+			}
+			if len(active) < 2 {
+				continue
+			}
+
+			to := familyMax
+			if i < len(events) {
+				to = events[i].addr.Prev()
+			}
+			codes := make([]string, 0, len(active))
+			for code := range active {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+
+			if last != nil && last.r.To().Next() == from && strings.Join(last.codes, ",") == strings.Join(codes, ",") {
+				last.r = netipx.IPRangeFrom(last.r.From(), to)
+				continue
+			}
+			last = &geoipOverlap{r: netipx.IPRangeFrom(from, to), codes: codes}
+			overlaps = append(overlaps, last)
+		}
+	}
+	return overlaps
+}
+
+// resolveOverlaps 检查代码之间的网段重叠，并按 custom.toml 中的 priority 生成互斥的代码。
+// 它在 include 指令和分组之前执行，因此包含了这些代码的代码和分组看到的是移除重叠之后的结果。
+//
+// 参与检查的代码为来自 data 目录和国家/地区级数据源（见 sources）的代码，以及 priority 中列出的代码，
+// 检查时已应用各代码自身的排除指令；包含了其他代码（include 指令）或输出为反向匹配的代码不参与检查。
+// 存在重叠时打印汇总信息，使用 -overlaps 时逐条列出重叠的网段、代码及其来源。
+//
+// priority 按优先级从高到低列出代码，每个代码移除所有优先级更高的代码已经包含的地址，
+// 因此重叠的地址只保留在优先级最高的代码中；未列出的代码保持不变。
+// priority 中的代码必须是已有代码，且不能包含其他代码或输出为反向匹配，否则返回错误。
+func resolveOverlaps(config *Config, dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective, sources map[string][]string) error {
+	priority := make([]string, 0, len(config.GeoIP.Priority))
+	rank := make(map[string]int, len(config.GeoIP.Priority))
+	for _, code := range config.GeoIP.Priority {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if _, found := dataDirMap[code]; !found {
+			return fmt.Errorf("priority: unknown code %s (groups and sets cannot be prioritized)", code)
+		}
+		if _, found := rank[code]; found {
+			return fmt.Errorf("priority: duplicate code %s", code)
+		}
+		if directive := directives[code]; directive != nil {
+			if len(directive.includes) > 0 {
+				return fmt.Errorf("priority: %s includes other codes and cannot be prioritized", code)
+			}
+			if directive.reverse {
+				return fmt.Errorf("priority: %s is a reverse code and cannot be prioritized", code)
+			}
+		}
+		rank[code] = len(priority)
+		priority = append(priority, code)
+	}
+
+	sets := make(map[string]*netipx.IPSet)
+	addSet := func(code string) error {
+		directive := directives[code]
+		if directive != nil && (len(directive.includes) > 0 || directive.reverse) {
+			return nil
+		}
+		builder, err := cidrsToIPSetBuilder(dataDirMap[code])
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		// 应用排除指令，结果与 resolveGeoIPDirectives 处理后的代码一致
+		if directive != nil {
+			for _, excluded := range directive.excludes {
+				prefix, err := cidrToPrefix(excluded)
+				if err != nil {
+					return fmt.Errorf("%s: %w", code, err)
+				}
+				builder.RemovePrefix(prefix)
+			}
+		}
+		if sets[code], err = builder.IPSet(); err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		return nil
+	}
+	for code := range sources {
+		if err := addSet(code); err != nil {
+			return err
+		}
+	}
+	for _, code := range priority {
+		if err := addSet(code); err != nil {
+			return err
+		}
+	}
+
+	overlaps := findOverlaps(sets)
+	if len(overlaps) > 0 {
+		fmt.Printf("Warning: %d address range(s) are claimed by more than one geoip code\n", len(overlaps))
+	}
+	if *overlapReport {
+		for _, overlap := range overlaps {
+			// 除优先级最高的代码外，priority 中列出的其他代码会移除该范围
+			kept := ""
+			for _, code := range overlap.codes {
+				if r, found := rank[code]; found && (kept == "" || r < rank[kept]) {
+					kept = code
+				}
+			}
+			var removed []string
+			for _, code := range overlap.codes {
+				if _, found := rank[code]; found && code != kept {
+					removed = append(removed, code)
+				}
+			}
+
+			described := make([]string, 0, len(overlap.codes))
+			for _, code := range overlap.codes {
+				if len(sources[code]) > 0 {
+					code = fmt.Sprintf("%s (%s)", code, strings.Join(sources[code], ", "))
+				}
+				described = append(described, code)
+			}
+			for _, prefix := range overlap.r.Prefixes() {
+				if len(removed) > 0 {
+					fmt.Printf("  %s: %s, removed from %s\n", prefix, strings.Join(described, ", "), strings.Join(removed, ", "))
+				} else {
+					fmt.Printf("  %s: %s\n", prefix, strings.Join(described, ", "))
+				}
+			}
+		}
+	}
+
+	// 按优先级从高到低移除已被更高优先级代码包含的地址
+	var taken netipx.IPSetBuilder
+	for _, code := range priority {
+		var builder netipx.IPSetBuilder
+		builder.AddSet(sets[code])
+		takenSet, err := taken.IPSet()
+		if err != nil {
+			return err
+		}
+		builder.RemoveSet(takenSet)
+		exclusive, err := builder.IPSet()
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		taken.AddSet(exclusive)
+		dataDirMap[code] = ipSetToCIDRs(exclusive)
+	}
+	return nil
+}
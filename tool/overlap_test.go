@@ -0,0 +1,130 @@
+package tool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go4.org/netipx"
+)
+
+// mustIPSet 将 CIDR 字符串转换为 netipx.IPSet。
+func mustIPSet(t *testing.T, strs ...string) *netipx.IPSet {
+	t.Helper()
+	builder, err := cidrsToIPSetBuilder(mustCIDRs(t, strs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := builder.IPSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestFindOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		sets map[string][]string
+		want []string // "范围 代码,代码"
+	}{
+		{
+			name: "disjoint",
+			sets: map[string][]string{"CN": {"1.0.1.0/24"}, "HK": {"1.0.2.0/24"}},
+		},
+		{
+			name: "nested",
+			sets: map[string][]string{"CN": {"1.0.0.0/16"}, "HK": {"1.0.2.0/24"}},
+			want: []string{"1.0.2.0-1.0.2.255 CN,HK"},
+		},
+		{
+			name: "adjacent ranges with the same codes are merged",
+			sets: map[string][]string{"CN": {"1.0.1.0/24", "1.0.2.0/24"}, "HK": {"1.0.0.0/16"}},
+			want: []string{"1.0.1.0-1.0.2.255 CN,HK"},
+		},
+		{
+			name: "three codes",
+			sets: map[string][]string{"CN": {"1.0.0.0/22"}, "HK": {"1.0.2.0/23"}, "JP": {"1.0.3.0/24"}},
+			want: []string{"1.0.2.0-1.0.2.255 CN,HK", "1.0.3.0-1.0.3.255 CN,HK,JP"},
+		},
+		{
+			name: "end of address space",
+			sets: map[string][]string{"A": {"255.255.255.0/24"}, "B": {"255.255.255.255/32"}, "C": {"ffff::/16"}, "D": {"ffff:ffff::/32"}},
+			want: []string{"255.255.255.255-255.255.255.255 A,B", "ffff:ffff::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff C,D"},
+		},
+		{
+			name: "families are separate",
+			sets: map[string][]string{"A": {"0.0.0.0/0"}, "B": {"::/0"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sets := make(map[string]*netipx.IPSet, len(test.sets))
+			for code, strs := range test.sets {
+				sets[code] = mustIPSet(t, strs...)
+			}
+			var got []string
+			for _, overlap := range findOverlaps(sets) {
+				got = append(got, fmt.Sprintf("%s %s", overlap.r, strings.Join(overlap.codes, ",")))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority []string
+		want     map[string][]string
+		wantErr  bool
+	}{
+		{
+			name: "no priority",
+			want: map[string][]string{"CN": {"1.0.0.0/22"}, "HK": {"1.0.2.0/24"}},
+		},
+		{
+			name:     "higher priority keeps the overlap",
+			priority: []string{"hk", "CN"},
+			want:     map[string][]string{"CN": {"1.0.0.0/23", "1.0.3.0/24"}, "HK": {"1.0.2.0/24"}},
+		},
+		{
+			name:     "excluded addresses are not taken",
+			priority: []string{"JP", "CN"},
+			want:     map[string][]string{"CN": {"1.0.0.0/24", "1.0.2.0/23"}, "JP": {"1.0.1.0/24"}},
+		},
+		{name: "unknown code", priority: []string{"XX"}, wantErr: true},
+		{name: "duplicate code", priority: []string{"CN", "cn"}, wantErr: true},
+		{name: "code with includes", priority: []string{"ASIA"}, wantErr: true},
+		{name: "reverse code", priority: []string{"NOT-US"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cidrList := testCIDRList(t, map[string][]string{
+				"CN":     {"1.0.0.0/22"},
+				"JP":     {"1.0.0.0/23"},
+				"ASIA":   {},
+				"NOT-US": {"8.8.8.0/24"},
+			})
+			directives := map[string]*geoipDirective{
+				"JP":     {path: "jp.txt", excludes: mustCIDRs(t, "1.0.0.0/24")},
+				"ASIA":   {path: "asia.txt", includes: []string{"CN"}},
+				"NOT-US": {path: "not-us.txt", reverse: true},
+			}
+			sources := map[string][]string{"CN": {"cn.txt"}, "HK": {"hk.txt"}, "JP": {"jp.txt"}, "ASIA": {"asia.txt"}, "NOT-US": {"not-us.txt"}}
+			config := &Config{}
+			config.GeoIP.Priority = test.priority
+
+			err := resolveOverlaps(config, cidrList, directives, sources)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			checkCIDRs(t, cidrList, test.want)
+		})
+	}
+}
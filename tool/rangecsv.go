@@ -0,0 +1,111 @@
+package tool
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// getCidrFromRangeCSV 读取基于地址范围的国家 CSV 文件，并按国家代码分组追加到 dataDirMap 中。
+// paths 为逗号分隔的文件列表。支持以下格式（均无表头）：
+//   - IP2Location LITE DB1（IPv4/IPv6）："16777216","16777471","US","United States of America"
+//   - DB-IP country lite："1.0.0.0","1.0.0.255","AU"
+func getCidrFromRangeCSV(paths string, dataDirMap map[string][]*router.CIDR) error {
+	wanted := wantedCodeSet()
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := readRangeCSV(path, wanted, dataDirMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRangeCSV 解析单个范围 CSV 文件，前三列依次为起始地址、结束地址和国家代码。
+// 国家代码为 "-" 或 "ZZ" 的行表示未分配或未知，会被忽略。
+func readRangeCSV(path string, wanted map[string]bool, dataDirMap map[string][]*router.CIDR) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if len(record) < 3 {
+			return fmt.Errorf("%s:%d: expected at least 3 columns, got %d", path, line, len(record))
+		}
+
+		cc := strings.ToUpper(strings.TrimSpace(record[2]))
+		if cc == "" || cc == "-" || cc == "ZZ" {
+			continue
+		}
+		if wanted != nil && !wanted[cc] {
+			continue
+		}
+
+		from, to, err := parseRangeBounds(strings.TrimSpace(record[0]), strings.TrimSpace(record[1]))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		cidrs, err := rangeToCIDRs(from, to)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		dataDirMap[cc] = append(dataDirMap[cc], cidrs...)
+	}
+	return nil
+}
+
+// parseRangeBounds 解析范围的起止地址，地址可以是文本形式（"1.0.0.0"）或十进制整数形式（"16777216"）。
+// 整数形式时，若结束地址不超过 32 位则视为 IPv4，否则视为 IPv6；IPv4 映射的 IPv6 地址会转换为 IPv4。
+func parseRangeBounds(fromStr, toStr string) (from, to netip.Addr, err error) {
+	if from, err = netip.ParseAddr(fromStr); err == nil {
+		if to, err = netip.ParseAddr(toStr); err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range end: %s", toStr)
+		}
+		return from.Unmap(), to.Unmap(), nil
+	}
+
+	fromInt, ok1 := new(big.Int).SetString(fromStr, 10)
+	toInt, ok2 := new(big.Int).SetString(toStr, 10)
+	if !ok1 || !ok2 || fromInt.Sign() < 0 || toInt.Sign() < 0 || toInt.BitLen() > 128 || fromInt.Cmp(toInt) > 0 {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid range: %s-%s", fromStr, toStr)
+	}
+
+	if toInt.BitLen() <= 32 {
+		var b4 [4]byte
+		fromInt.FillBytes(b4[:])
+		from = netip.AddrFrom4(b4)
+		toInt.FillBytes(b4[:])
+		to = netip.AddrFrom4(b4)
+		return from, to, nil
+	}
+
+	var b16 [16]byte
+	fromInt.FillBytes(b16[:])
+	from = netip.AddrFrom16(b16)
+	toInt.FillBytes(b16[:])
+	to = netip.AddrFrom16(b16)
+	return from.Unmap(), to.Unmap(), nil
+}
@@ -0,0 +1,43 @@
+package tool
+
+import (
+	"testing"
+)
+
+func TestParseRangeBounds(t *testing.T) {
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+		wantErr          bool
+	}{
+		{from: "1.0.0.0", to: "1.0.0.255", wantFrom: "1.0.0.0", wantTo: "1.0.0.255"},
+		{from: "2001:db8::", to: "2001:db8::ffff", wantFrom: "2001:db8::", wantTo: "2001:db8::ffff"},
+		{from: "::ffff:1.0.0.0", to: "::ffff:1.0.0.255", wantFrom: "1.0.0.0", wantTo: "1.0.0.255"},
+		{from: "16777216", to: "16777471", wantFrom: "1.0.0.0", wantTo: "1.0.0.255"},
+		{from: "0", to: "4294967295", wantFrom: "0.0.0.0", wantTo: "255.255.255.255"},
+		// 结束地址超过 32 位时视为 IPv6
+		{from: "0", to: "4294967296", wantFrom: "::", wantTo: "::1:0:0"},
+		// IPv4 映射的 IPv6 整数形式转换为 IPv4
+		{from: "281470698520576", to: "281470698520831", wantFrom: "1.0.0.0", wantTo: "1.0.0.255"},
+		{from: "42540766411282592856903984951653826560", to: "42540766411282592856903984951653826815", wantFrom: "2001:db8::", wantTo: "2001:db8::ff"},
+		{from: "1.0.0.0", to: "x", wantErr: true},
+		{from: "16777471", to: "16777216", wantErr: true},
+		{from: "-1", to: "16777216", wantErr: true},
+		{from: "0", to: "340282366920938463463374607431768211456", wantErr: true},
+		{from: "a", to: "b", wantErr: true},
+	}
+
+	for _, test := range tests {
+		from, to, err := parseRangeBounds(test.from, test.to)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s-%s: got error %v, want error %v", test.from, test.to, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if from.String() != test.wantFrom || to.String() != test.wantTo {
+			t.Errorf("%s-%s: got %s-%s, want %s-%s", test.from, test.to, from, to, test.wantFrom, test.wantTo)
+		}
+	}
+}
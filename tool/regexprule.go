@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regexpSampleDomains 是用于检查正则表达式是否过于宽泛的样本域名，覆盖常见的域名形态。
+// 能够匹配全部样本的正则表达式几乎会匹配任意域名。
+var regexpSampleDomains = []string{
+	"a",
+	"localhost",
+	"example.com",
+	"www.google.com",
+	"cdn-01.static.example.co.uk",
+	"xn--fiqs8s.cn",
+	"192.168.1.1",
+	"test_1.internal",
+}
+
+// checkRegexpRule 使用与 xray 相同的 Go 正则表达式引擎（RE2 语法）编译 regexp 规则的值。
+// 无法编译时返回错误；可以编译但没有锚定（既不以 ^ 开头也不以 $ 结尾）或匹配几乎所有域名时返回警告。
+// 错误和警告的列号指向 token 中的规则值。
+func checkRegexpRule(token ruleToken, pattern string) (warning, err error) {
+	// 规则值位于记号末尾（类型前缀之后）
+	offset := len(token.text) - len(pattern)
+
+	re, compileErr := regexp.Compile(pattern)
+	if compileErr != nil {
+		return nil, tokenError(token, offset, "invalid regexp: %v", compileErr)
+	}
+
+	broad := true
+	for _, domain := range regexpSampleDomains {
+		if !re.MatchString(domain) {
+			broad = false
+			break
+		}
+	}
+	if broad {
+		return tokenError(token, offset, "regexp %q matches nearly every domain", pattern), nil
+	}
+
+	anchored := strings.HasPrefix(pattern, "^") || strings.HasPrefix(pattern, `\A`) ||
+		strings.HasSuffix(pattern, "$") || strings.HasSuffix(pattern, `\z`)
+	if !anchored {
+		return tokenError(token, offset, "regexp %q is not anchored and matches anywhere in the domain", pattern), nil
+	}
+	return nil, nil
+}
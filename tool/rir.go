@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+// getCidrFromRIR 读取 RIR 的 delegated 统计文件（如 delegated-apnic-latest、
+// delegated-ripencc-extended-latest），并按国家代码分组追加到 dataDirMap 中。
+// path 可以是单个文件，也可以是包含多个统计文件的目录。
+func getCidrFromRIR(path string, dataDirMap map[string][]*router.CIDR) error {
+	wanted := wantedCodeSet()
+	return filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil // 跳过目录
+		}
+		return readRIRFile(file, wanted, dataDirMap)
+	})
+}
+
+// readRIRFile 解析单个 RIR 统计文件。每条记录的格式为：
+//
+//	registry|cc|type|start|value|date|status[|opaque-id[|extensions...]]
+//
+// 其中 ipv4 记录的 value 是地址数量（不一定是 2 的幂），ipv6 记录的 value 是前缀长度。
+// 版本行、汇总行、asn 记录以及未分配（available/reserved）的记录会被忽略。
+func readRIRFile(path string, wanted map[string]bool, dataDirMap map[string][]*router.CIDR) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if isEmpty(line) || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) < 7 {
+			continue // 版本行（字段较少）
+		}
+		cc, recordType, start, value, status := strings.ToUpper(fields[1]), fields[2], fields[3], fields[4], fields[6]
+		if status != "allocated" && status != "assigned" {
+			continue // 汇总行（status 为 summary）及未分配的地址
+		}
+		if cc == "" || cc == "*" || cc == "ZZ" {
+			continue
+		}
+		if wanted != nil && !wanted[cc] {
+			continue
+		}
+
+		var cidrs []*router.CIDR
+		switch recordType {
+		case "ipv4":
+			cidrs, err = rirIPv4Range(start, value)
+		case "ipv6":
+			cidrs, err = rirIPv6Prefix(start, value)
+		default:
+			continue // asn 等记录
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		dataDirMap[cc] = append(dataDirMap[cc], cidrs...)
+	}
+	return scanner.Err()
+}
+
+// rirIPv4Range 将 ipv4 记录的起始地址和地址数量转换为覆盖该范围的 CIDR 列表。
+func rirIPv4Range(start, count string) ([]*router.CIDR, error) {
+	from, err := netip.ParseAddr(start)
+	if err != nil || !from.Is4() {
+		return nil, fmt.Errorf("invalid ipv4 start address: %s", start)
+	}
+	n, err := strconv.ParseUint(count, 10, 32)
+	if err != nil || n == 0 {
+		return nil, fmt.Errorf("invalid ipv4 address count: %s", count)
+	}
+
+	b := from.As4()
+	end := uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])
+	end += n - 1
+	if end > 0xffffffff {
+		return nil, fmt.Errorf("ipv4 range overflows: %s+%s", start, count)
+	}
+	to := netip.AddrFrom4([4]byte{byte(end >> 24), byte(end >> 16), byte(end >> 8), byte(end)})
+	return rangeToCIDRs(from, to)
+}
+
+// rirIPv6Prefix 将 ipv6 记录的起始地址和前缀长度转换为 CIDR。
+func rirIPv6Prefix(start, bits string) ([]*router.CIDR, error) {
+	cidr, err := ParseIP(start + "/" + bits)
+	if err != nil {
+		return nil, err
+	}
+	if len(cidr.GetIp()) != 16 {
+		return nil, fmt.Errorf("invalid ipv6 prefix: %s/%s", start, bits)
+	}
+	return []*router.CIDR{cidr}, nil
+}
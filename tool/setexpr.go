@@ -0,0 +1,284 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+	"go4.org/netipx"
+)
+
+// addGeoIPSets 计算 custom.toml 中 [geoip.sets] 定义的集合表达式，每个表达式生成一个以其名称命名的代码。
+//
+// 表达式的写法：
+//   - 操作数：已有代码（如 CN、PRIVATE、AS4134）、其他集合、IP 或 CIDR（如 ::/0），以及表示全部地址的 ALL
+//   - 并集：A ∪ B、A + B、A | B
+//   - 交集：A ∩ B、A & B
+//   - 差集：A - B、A \ B（"-" 两侧必须有空格，否则视为代码名的一部分，如 GREATER-CN）
+//   - 补集：!A、~A、¬A（相对于 ALL）
+//   - 括号：(A ∪ B) - C
+//
+// 补集优先级最高，其次为交集，并集和差集优先级相同且从左到右结合。
+// 例如 "ALL - CN - PRIVATE"、"CN ∩ ::/0"、"CN ∪ PRIVATE ∪ AS4134"。
+// 集合可以引用其他集合，但不能形成循环；集合名不能与已有代码重名。
+// 反向匹配的代码（见 geoipDirective）不能作为操作数，需要补集时使用 !A。
+func addGeoIPSets(config *Config, dataDirMap map[string][]*router.CIDR, directives map[string]*geoipDirective) error {
+	exprs := make(map[string]string, len(config.GeoIP.Sets))
+	names := make(map[string]string, len(config.GeoIP.Sets)) // 代码 -> 配置中的原始名称
+	for name, expr := range config.GeoIP.Sets {
+		code := strings.ToUpper(strings.TrimSpace(name))
+		if _, found := dataDirMap[code]; found {
+			return fmt.Errorf("%s [geoip.sets.%s]: conflicts with an existing code", *customPath, name)
+		}
+		exprs[code] = expr
+		names[code] = name
+	}
+
+	results := make(map[string]*netipx.IPSet, len(exprs))
+	walker := newDependencyWalker[string]("set")
+
+	var evaluate func(code string) (*netipx.IPSet, error)
+	evaluate = func(code string) (*netipx.IPSet, error) {
+		if done, err := walker.enter(code); done || err != nil {
+			return results[code], err
+		}
+
+		parser := &setExprParser{lookup: func(operand string) (*netipx.IPSet, error) {
+			if _, found := exprs[operand]; found {
+				return evaluate(operand)
+			}
+			cidrs, found := dataDirMap[operand]
+			if !found {
+				return nil, fmt.Errorf("unknown code %s", operand)
+			}
+			if isReverseCode(directives, operand) {
+				return nil, fmt.Errorf("%s is a reverse code, use !%s for its complement", operand, operand)
+			}
+			builder, err := cidrsToIPSetBuilder(cidrs)
+			if err != nil {
+				return nil, err
+			}
+			return builder.IPSet()
+		}}
+		set, err := parser.parse(exprs[code])
+		if err != nil {
+			// 被引用的集合出错时，错误已经带有其所在位置
+			var exprErr *setExprError
+			if errors.As(err, &exprErr) {
+				return nil, err
+			}
+			return nil, &setExprError{name: names[code], err: err}
+		}
+		walker.leave()
+		results[code] = set
+		return set, nil
+	}
+
+	// 按代码排序处理，保证错误信息稳定
+	codes := make([]string, 0, len(exprs))
+	for code := range exprs {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		set, err := evaluate(code)
+		if err != nil {
+			return err
+		}
+		dataDirMap[code] = ipSetToCIDRs(set)
+	}
+	return nil
+}
+
+// setExprError 表示某个集合表达式的错误。
+type setExprError struct {
+	name string // 配置中的集合名称
+	err  error
+}
+
+func (e *setExprError) Error() string {
+	return fmt.Sprintf("%s [geoip.sets.%s]: %v", *customPath, e.name, e.err)
+}
+
+func (e *setExprError) Unwrap() error {
+	return e.err
+}
+
+// allAddresses 返回包含全部 IPv4 和 IPv6 地址的集合。
+func allAddresses() *netipx.IPSetBuilder {
+	builder := new(netipx.IPSetBuilder)
+	builder.AddPrefix(netip.MustParsePrefix("0.0.0.0/0"))
+	builder.AddPrefix(netip.MustParsePrefix("::/0"))
+	return builder
+}
+
+// setExprParser 是集合表达式的递归下降解析器，解析的同时直接计算结果。
+type setExprParser struct {
+	lookup func(code string) (*netipx.IPSet, error) // 根据代码查找集合
+	tokens []string
+	pos    int
+}
+
+// tokenizeSetExpr 将表达式拆分为操作数、运算符和括号。
+// 单字符运算符总是单独成为一个记号；"-" 和 "\" 只有两侧为空白时才视为运算符。
+func tokenizeSetExpr(expr string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		case strings.ContainsRune("()∪∩¬+|&!~", r):
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// parse 解析并计算整个表达式。
+func (p *setExprParser) parse(expr string) (*netipx.IPSet, error) {
+	p.tokens, p.pos = tokenizeSetExpr(expr), 0
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	set, err := p.parseUnion()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return set, nil
+}
+
+// peek 返回当前记号，表达式结束时返回空字符串。
+func (p *setExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parseUnion 解析并集和差集：term ((∪ | + | "|" | - | \) term)*
+func (p *setExprParser) parseUnion() (*netipx.IPSet, error) {
+	left, err := p.parseIntersection()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != "∪" && op != "+" && op != "|" && op != "-" && op != `\` {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseIntersection()
+		if err != nil {
+			return nil, err
+		}
+
+		var builder netipx.IPSetBuilder
+		builder.AddSet(left)
+		if op == "-" || op == `\` {
+			builder.RemoveSet(right)
+		} else {
+			builder.AddSet(right)
+		}
+		if left, err = builder.IPSet(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseIntersection 解析交集：unary ((∩ | &) unary)*
+func (p *setExprParser) parseIntersection() (*netipx.IPSet, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "∩" || op == "&"; op = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		var builder netipx.IPSetBuilder
+		builder.AddSet(left)
+		builder.Intersect(right)
+		if left, err = builder.IPSet(); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// parseUnary 解析补集：(! | ~ | ¬) unary | primary
+func (p *setExprParser) parseUnary() (*netipx.IPSet, error) {
+	switch p.peek() {
+	case "!", "~", "¬":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		builder := allAddresses()
+		builder.RemoveSet(operand)
+		return builder.IPSet()
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary 解析括号表达式或操作数。
+func (p *setExprParser) parsePrimary() (*netipx.IPSet, error) {
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "(":
+		p.pos++
+		set, err := p.parseUnion()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return set, nil
+	case ")", "∪", "∩", "+", "|", "&", "-", `\`:
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	p.pos++
+
+	if strings.EqualFold(token, "ALL") {
+		return allAddresses().IPSet()
+	}
+	// 含有 "." 或 ":" 的操作数视为 IP 或 CIDR，其余视为代码
+	if strings.ContainsAny(token, ".:") {
+		cidr, err := ParseIP(token)
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := cidrToPrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		var builder netipx.IPSetBuilder
+		builder.AddPrefix(prefix)
+		return builder.IPSet()
+	}
+	return p.lookup(strings.ToUpper(token))
+}
@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeSetExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "ALL - CN - PRIVATE", want: []string{"ALL", "-", "CN", "-", "PRIVATE"}},
+		{expr: "GREATER-CN∪HK", want: []string{"GREATER-CN", "∪", "HK"}},
+		{expr: "(CN|HK)&!PRIVATE", want: []string{"(", "CN", "|", "HK", ")", "&", "!", "PRIVATE"}},
+		{expr: "¬CN ∩ ::/0", want: []string{"¬", "CN", "∩", "::/0"}},
+		{expr: `ALL \ CN`, want: []string{"ALL", `\`, "CN"}},
+		{expr: " \t", want: nil},
+	}
+	for _, test := range tests {
+		if got := tokenizeSetExpr(test.expr); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestGeoIPSets(t *testing.T) {
+	tests := []struct {
+		name    string
+		sets    map[string]string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "union and difference",
+			sets: map[string]string{"x": "CN + HK - 1.0.1.128/25", "y": `CN ∪ HK \ HK`},
+			want: map[string][]string{
+				"X": {"1.0.1.0/25", "1.0.2.0/24"},
+				"Y": {"1.0.1.0/24"},
+			},
+		},
+		{
+			name: "precedence",
+			sets: map[string]string{"x": "CN | HK & US", "y": "(CN | HK) & 1.0.2.0/23"},
+			want: map[string][]string{
+				"X": {"1.0.1.0/24"},
+				"Y": {"1.0.2.0/24"},
+			},
+		},
+		{
+			name: "complement",
+			sets: map[string]string{"x": "!CN & 1.0.0.0/22", "y": "ALL - ::/0 - 128.0.0.0/1 - 0.0.0.0/2"},
+			want: map[string][]string{
+				"X": {"1.0.0.0/24", "1.0.2.0/23"},
+				"Y": {"64.0.0.0/2"},
+			},
+		},
+		{
+			name: "set referencing another set",
+			sets: map[string]string{"greater-cn": "CN ∪ HK", "x": "GREATER-CN ∩ 1.0.1.0/24"},
+			want: map[string][]string{"X": {"1.0.1.0/24"}},
+		},
+		{name: "cycle", sets: map[string]string{"a": "B", "b": "A"}, wantErr: true},
+		{name: "unknown code", sets: map[string]string{"x": "CN + XX"}, wantErr: true},
+		{name: "reverse code", sets: map[string]string{"x": "CN + NOT-US"}, wantErr: true},
+		{name: "conflicts with a code", sets: map[string]string{"cn": "HK"}, wantErr: true},
+		{name: "missing paren", sets: map[string]string{"x": "(CN + HK"}, wantErr: true},
+		{name: "dangling operator", sets: map[string]string{"x": "CN -"}, wantErr: true},
+		{name: "empty", sets: map[string]string{"x": ""}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cidrList := testCIDRList(t, map[string][]string{"NOT-US": {"8.8.8.0/24"}})
+			directives := map[string]*geoipDirective{"NOT-US": {path: "not-us.txt", reverse: true}}
+			config := &Config{}
+			config.GeoIP.Sets = test.sets
+
+			err := addGeoIPSets(config, cidrList, directives)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			checkCIDRs(t, cidrList, test.want)
+		})
+	}
+}
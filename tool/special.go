@@ -0,0 +1,219 @@
+package tool
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	router "github.com/xtls/xray-core/app/router"
+	"go4.org/netipx"
+)
+
+// ianaRegistryFS 内嵌了 IANA IPv4/IPv6 特殊用途地址注册表（CSV 格式）。
+// 来源：
+//   - https://www.iana.org/assignments/iana-ipv4-special-registry/iana-ipv4-special-registry-1.csv
+//   - https://www.iana.org/assignments/iana-ipv6-special-registry/iana-ipv6-special-registry-1.csv
+//
+//go:embed iana/*.csv
+var ianaRegistryFS embed.FS
+
+// multicastPrefixes 是组播地址范围，它们不在特殊用途地址注册表中（RFC 5771、RFC 4291）。
+var multicastPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// translationPrefixes 是内嵌 IPv4 地址的 IPv4 映射地址和 IPv4/IPv6 转换地址（RFC 4291、RFC 6052、RFC 8215）。
+// 这些地址是否可以全局访问取决于其中的 IPv4 地址，因此不属于 PRIVATE 和 RESERVED。
+var translationPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// footnotePattern 匹配注册表中的脚注标记，例如 "False [1]" 中的 " [1]"。
+var footnotePattern = regexp.MustCompile(`\s*\[\d+\]`)
+
+// specialBlock 是特殊用途地址注册表中的一个地址块。
+type specialBlock struct {
+	prefix            netip.Prefix
+	name              string
+	globallyReachable bool // Globally Reachable 为 True 或 N/A
+	reservedByProto   bool // Reserved-by-Protocol 为 True
+}
+
+// parseSpecialRegistry 解析 IANA 特殊用途地址注册表 CSV。
+// 同一单元格中的多个地址块（如 "192.0.0.170/32, 192.0.0.171/32"）会拆分为多条记录；
+// 已废弃的地址块（Termination Date 不为 N/A）视为不可全局访问。
+func parseSpecialRegistry(r io.Reader, source string) ([]*specialBlock, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", source, err)
+	}
+	columns := make(map[string]int, len(header))
+	for idx, name := range header {
+		columns[strings.TrimSpace(name)] = idx
+	}
+	for _, name := range []string{"Address Block", "Name", "Termination Date", "Globally Reachable", "Reserved-by-Protocol"} {
+		if _, found := columns[name]; !found {
+			return nil, fmt.Errorf("%s: missing %q column", source, name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		return strings.TrimSpace(footnotePattern.ReplaceAllString(record[columns[name]], ""))
+	}
+
+	var blocks []*specialBlock
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+
+		terminated := field(record, "Termination Date") != "N/A"
+		reachable := field(record, "Globally Reachable")
+		for _, block := range strings.Split(field(record, "Address Block"), ",") {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(block))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+			blocks = append(blocks, &specialBlock{
+				prefix:            prefix.Masked(),
+				name:              field(record, "Name"),
+				globallyReachable: !terminated && (reachable == "True" || reachable == "N/A"),
+				reservedByProto:   field(record, "Reserved-by-Protocol") == "True",
+			})
+		}
+	}
+	return blocks, nil
+}
+
+// loadSpecialRegistry 读取内嵌的注册表以及 -special 指定的注册表文件。
+// 额外文件中与已有地址块相同的记录会覆盖已有记录；使用 -specialreplace 时不读取内嵌的注册表。
+func loadSpecialRegistry() ([]*specialBlock, error) {
+	var blocks []*specialBlock
+	if !*specialReplace {
+		paths, err := fs.Glob(ianaRegistryFS, "iana/*.csv")
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			file, err := ianaRegistryFS.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := parseSpecialRegistry(file, path)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, parsed...)
+		}
+	}
+
+	for _, path := range strings.Split(*specialPath, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseSpecialRegistry(file, path)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, parsed...)
+	}
+
+	// 相同地址块只保留最后出现的记录
+	index := make(map[netip.Prefix]int)
+	var merged []*specialBlock
+	for _, block := range blocks {
+		if idx, found := index[block.prefix]; found {
+			merged[idx] = block
+			continue
+		}
+		index[block.prefix] = len(merged)
+		merged = append(merged, block)
+	}
+	return merged, nil
+}
+
+// specialPurpose 根据特殊用途地址注册表向 dataDirMap 中添加以下内置代码：
+//   - PRIVATE：所有不可全局访问的地址以及组播地址。更具体（前缀更长）的记录优先，
+//     例如 2001::/23 不可全局访问，但其中的 2001::/32（TEREDO）不属于 PRIVATE
+//   - RESERVED：Reserved-by-Protocol 为 True 的地址块
+//   - PRIVATE 和 RESERVED 都不包含 IPv4 映射地址和 IPv4/IPv6 转换地址（见 translationPrefixes）
+//   - DOCUMENTATION、LOOPBACK、LINKLOCAL：名称中包含对应用途的地址块
+//   - MULTICAST：组播地址
+func specialPurpose(dataDirMap map[string][]*router.CIDR) error {
+	blocks, err := loadSpecialRegistry()
+	if err != nil {
+		return err
+	}
+
+	// 按前缀长度从短到长处理，使更具体的记录覆盖更宽泛的记录
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].prefix.Bits() < blocks[j].prefix.Bits()
+	})
+
+	builders := map[string]*netipx.IPSetBuilder{
+		"PRIVATE":       new(netipx.IPSetBuilder),
+		"RESERVED":      new(netipx.IPSetBuilder),
+		"DOCUMENTATION": new(netipx.IPSetBuilder),
+		"LOOPBACK":      new(netipx.IPSetBuilder),
+		"LINKLOCAL":     new(netipx.IPSetBuilder),
+		"MULTICAST":     new(netipx.IPSetBuilder),
+	}
+	for _, block := range blocks {
+		name := strings.ToLower(block.name)
+		if block.globallyReachable {
+			builders["PRIVATE"].RemovePrefix(block.prefix)
+		} else {
+			builders["PRIVATE"].AddPrefix(block.prefix)
+		}
+		if block.reservedByProto {
+			builders["RESERVED"].AddPrefix(block.prefix)
+		}
+		if strings.Contains(name, "documentation") {
+			builders["DOCUMENTATION"].AddPrefix(block.prefix)
+		}
+		if strings.Contains(name, "loopback") {
+			builders["LOOPBACK"].AddPrefix(block.prefix)
+		}
+		if strings.Contains(name, "link local") || strings.Contains(name, "link-local") {
+			builders["LINKLOCAL"].AddPrefix(block.prefix)
+		}
+	}
+	for _, prefix := range multicastPrefixes {
+		builders["PRIVATE"].AddPrefix(prefix)
+		builders["MULTICAST"].AddPrefix(prefix)
+	}
+	for _, prefix := range translationPrefixes {
+		builders["PRIVATE"].RemovePrefix(prefix)
+		builders["RESERVED"].RemovePrefix(prefix)
+	}
+
+	for code, builder := range builders {
+		set, err := builder.IPSet()
+		if err != nil {
+			return fmt.Errorf("%s: %w", code, err)
+		}
+		dataDirMap[code] = ipSetToCIDRs(set)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package tool
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	router "github.com/xtls/xray-core/app/router"
+)
+
+func TestParseSpecialRegistry(t *testing.T) {
+	const registry = `Address Block,Name,RFC,Allocation Date,Termination Date,Source,Destination,Forwardable,Globally Reachable,Reserved-by-Protocol
+10.0.0.0/8,Private-Use,[RFC1918],1996-02,N/A,True,True,True,False,False
+"192.0.0.170/32, 192.0.0.171/32",NAT64/DNS64 Discovery,"[RFC8880]
+[RFC7050]",2013-02,N/A,False,False,False,False,True
+192.88.99.0/24,Deprecated (6to4 Relay Anycast),[RFC7526],2001-06,2015-03,N/A,N/A,N/A,N/A,N/A
+2001::/32,TEREDO,[RFC4380],2006-01,N/A,True,True,True,N/A [2],False
+`
+	blocks, err := parseSpecialRegistry(strings.NewReader(registry), "test.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []specialBlock{
+		{prefix: netip.MustParsePrefix("10.0.0.0/8"), name: "Private-Use"},
+		{prefix: netip.MustParsePrefix("192.0.0.170/32"), name: "NAT64/DNS64 Discovery", reservedByProto: true},
+		{prefix: netip.MustParsePrefix("192.0.0.171/32"), name: "NAT64/DNS64 Discovery", reservedByProto: true},
+		{prefix: netip.MustParsePrefix("192.88.99.0/24"), name: "Deprecated (6to4 Relay Anycast)"},
+		{prefix: netip.MustParsePrefix("2001::/32"), name: "TEREDO", globallyReachable: true},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d", len(blocks), len(want))
+	}
+	for idx, block := range blocks {
+		if *block != want[idx] {
+			t.Errorf("block %d: got %+v, want %+v", idx, *block, want[idx])
+		}
+	}
+
+	if _, err := parseSpecialRegistry(strings.NewReader("Address Block,Name\n"), "bad.csv"); err == nil {
+		t.Error("missing columns: got no error")
+	}
+}
+
+func TestSpecialPurpose(t *testing.T) {
+	cidrList := make(map[string][]*router.CIDR)
+	if err := specialPurpose(cidrList); err != nil {
+		t.Fatal(err)
+	}
+	sets := make(map[string]func(string) bool, len(cidrList))
+	for code, cidrs := range cidrList {
+		builder, err := cidrsToIPSetBuilder(cidrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, err := builder.IPSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets[code] = func(ip string) bool { return set.Contains(netip.MustParseAddr(ip)) }
+	}
+
+	tests := []struct {
+		code string
+		ip   string
+		want bool
+	}{
+		{"PRIVATE", "10.1.2.3", true},
+		{"PRIVATE", "192.168.1.1", true},
+		{"PRIVATE", "100.64.0.1", true},
+		{"PRIVATE", "fd00::1", true},
+		{"PRIVATE", "224.0.0.1", true},
+		{"PRIVATE", "8.8.8.8", false},
+		{"PRIVATE", "2001::1", false}, // TEREDO 比 2001::/23 更具体
+		{"PRIVATE", "::ffff:10.0.0.1", false},
+		{"PRIVATE", "64:ff9b::808:808", false},
+		{"PRIVATE", "64:ff9b:1::1", false},
+		{"RESERVED", "127.0.0.1", true},
+		{"RESERVED", "::ffff:8.8.8.8", false},
+		{"RESERVED", "10.1.2.3", false},
+		{"LOOPBACK", "127.0.0.1", true},
+		{"LOOPBACK", "::1", true},
+		{"DOCUMENTATION", "2001:db8::1", true},
+		{"DOCUMENTATION", "198.51.100.1", true},
+		{"LINKLOCAL", "169.254.1.1", true},
+		{"LINKLOCAL", "fe80::1", true},
+		{"MULTICAST", "ff02::1", true},
+		{"MULTICAST", "10.1.2.3", false},
+	}
+	for _, test := range tests {
+		contains, found := sets[test.code]
+		if !found {
+			t.Fatalf("%s: code not generated", test.code)
+		}
+		if got := contains(test.ip); got != test.want {
+			t.Errorf("%s contains %s: got %v, want %v", test.code, test.ip, got, test.want)
+		}
+	}
+}
@@ -23,6 +23,30 @@ var (
 	directPath = flag.String("direct", "./domain_data/cn", "Path to the CN domain list file")
 	proxyPath  = flag.String("proxy", "./domain_data/gfw", "Path to the GFW domain list file")
 	customPath = flag.String("custom", "./custom.toml", "Path to the custom configuration file")
+
+	// geoip 数据源相关参数
+	maxmindCSVPath  = flag.String("maxmindcsv", "", "Path to the GeoLite2 Country CSV directory")
+	mmdbPath        = flag.String("mmdb", "", "Path to a country MMDB database (GeoLite2-Country, DB-IP lite, ipinfo country)")
+	rirPath         = flag.String("rir", "", "Path to an RIR delegated stats file or a directory of them")
+	ip2locationPath = flag.String("ip2location", "", "Comma-separated IP2Location LITE DB1 CSV files")
+	dbipPath        = flag.String("dbip", "", "Comma-separated DB-IP country lite CSV files")
+	ip2asnPath      = flag.String("ip2asn", "", "Comma-separated IPtoASN TSV files (ip2asn-v4.tsv, ip2asn-combined.tsv, .gz supported)")
+	pfx2asPath      = flag.String("pfx2as", "", "Comma-separated CAIDA pfx2as files (.gz supported)")
+	mrtPath         = flag.String("mrt", "", "Comma-separated MRT TABLE_DUMP_V2 RIB dumps (.gz and .bz2 supported)")
+	mmdbName        = flag.String("mmdbname", "", "Name of the generated MMDB file in geoip mode, e.g. Country.mmdb; only country-level codes are written (empty to skip)")
+	strictMode      = flag.Bool("strict", false, "Fail geoip generation if any line in the source files cannot be parsed")
+	warnCanonical   = flag.Bool("warncanon", false, "Warn about every geoip source line changed by canonicalization")
+	splitFamily     = flag.Bool("split", false, "Also emit IPv4-only and IPv6-only variants of every geoip code")
+	ipv4Suffix      = flag.String("ipv4suffix", "-IPV4", "Suffix of the IPv4-only geoip code variants")
+	ipv6Suffix      = flag.String("ipv6suffix", "-IPV6", "Suffix of the IPv6-only geoip code variants")
+	specialPath     = flag.String("special", "", "Comma-separated IANA special-purpose registry CSV files extending or overriding the embedded registry")
+	specialReplace  = flag.Bool("specialreplace", false, "Use only the -special registry files instead of the embedded IANA registry")
+	overlapReport   = flag.Bool("overlaps", false, "List every address range claimed by more than one geoip code")
+	wantedCodes     = flag.String("wanted", "", "Comma-separated country codes to keep from multi-country sources (empty keeps all)")
+
+	// geosite 相关参数
+	laxRegexp    = flag.Bool("laxregexp", false, "Drop invalid regexp rules with a warning instead of failing geosite generation")
+	excludeAttrs = flag.String("excludeattrs", "", "Exclude rules with certain attributes from certain lists, e.g. google@cn@ads,apple@cn (comma-separated)")
 )
 
 // Config 结构体用于解析 custom.toml 文件中的自定义规则。
@@ -35,6 +59,16 @@ type Config struct {
 		Add    []string
 		Remove []string
 	}
+	GeoIP struct { // geoip 生成配置
+		ASNs          []uint32            // 需要生成 AS<编号> 代码的 ASN 列表
+		ASNGroups     map[string][]uint32 // ASN 分组，例如 CLOUDFLARE = [13335, 209242]
+		Cloud         []CloudSource       // 云服务商 IP 范围数据源
+		Extract       []ExtractSource     // 通用 JSON/CSV 数据源
+		Groups        map[string][]string // 代码分组，例如 GREATER-CN = ["CN", "HK", "MO", "TW"]
+		BuiltinGroups bool                // 是否生成内置的大洲和欧盟分组（CONTINENT-AS、EU 等），默认不生成
+		Sets          map[string]string   // 集合表达式，例如 NOT-CN-PRIVATE = "ALL - CN - PRIVATE"
+		Priority      []string            // 重叠时的代码优先级（从高到低），例如 ["CN", "HK"]
+	}
 }
 
 // read 读取指定路径文件的所有非空行，并返回一个字符串切片。
@@ -152,8 +186,18 @@ func RunTool() {
 		if *datName == "" {
 			*datName = "geoip.dat"
 		}
-		geoip()      // 生成 geoip.dat
-		gen_sha256() // 生成 SHA256 校验和文件
+		var config Config
+		// 读取并解析 custom.toml 配置文件，文件不存在时使用空配置
+		if _, err := toml.DecodeFile(*customPath, &config); err != nil && !os.IsNotExist(err) {
+			fmt.Println("Failed:", err)
+			os.Exit(1)
+		}
+
+		geoip(&config)       // 生成 geoip.dat（以及可选的 MMDB 文件）
+		gen_sha256(*datName) // 生成 SHA256 校验和文件
+		if *mmdbName != "" {
+			gen_sha256(*mmdbName)
+		}
 		os.Exit(0)
 	}
 
@@ -174,8 +218,8 @@ func RunTool() {
 		AddData(*proxyPath, config.Proxy.Add)
 		RemoveData(*proxyPath, config.Proxy.Remove)
 
-		geositeEntry() // 生成 geosite.dat
-		gen_sha256()   // 生成 SHA256 校验和文件
+		geositeEntry()       // 生成 geosite.dat
+		gen_sha256(*datName) // 生成 SHA256 校验和文件
 
 		os.Exit(0)
 	}
@@ -183,12 +227,12 @@ func RunTool() {
 	fmt.Println("-mode geoip or -mode geosite")
 }
 
-// gen_sha256 为生成的 dat 文件计算 SHA256 校验和并写入同名文件（后缀为 .sha256sum）。
-func gen_sha256() {
-	file_path := filepath.Join(*outputPath, *datName)
+// gen_sha256 为输出目录中生成的文件计算 SHA256 校验和并写入同名文件（后缀为 .sha256sum）。
+func gen_sha256(name string) {
+	file_path := filepath.Join(*outputPath, name)
 	file, _ := os.ReadFile(file_path)
 	sum := sha256.Sum256(file)
 	// 格式：<SHA256 校验和>  <文件名>
-	str := hex.EncodeToString(sum[:]) + "  " + *datName
+	str := hex.EncodeToString(sum[:]) + "  " + name
 	os.WriteFile(file_path+".sha256sum", []byte(str), 0644)
 }